@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/logsink/grafana-logship-datasource/pkg/logship/metrics"
 	"github.com/logsink/grafana-logship-datasource/pkg/logship/models"
 )
 
@@ -81,6 +82,7 @@ type LogshipJwtAuth struct {
 	user  string
 	pass  string
 	host  string
+	uid   string
 }
 
 func (*LogshipJwtAuth) WithUserContextFromQueryRequest(ctx context.Context, req *backend.QueryDataRequest) (context.Context, error) {
@@ -104,6 +106,7 @@ func NewJwtAuth(settings *backend.DataSourceInstanceSettings, datasource *models
 		user:  user,
 		pass:  pass,
 		host:  datasource.ClusterURL,
+		uid:   settings.UID,
 	}, nil
 }
 
@@ -111,8 +114,12 @@ func (a *LogshipJwtAuth) AuthenticateRequest(ctx context.Context, client *http.C
 	if len(a.token) == 0 {
 		err := a.authenticateJwt(client)
 		if err != nil {
+			metrics.AuthTokenFailed(a.uid, "jwt")
 			return fmt.Errorf("failed to authenticate JWT token: %w", err)
 		}
+		metrics.AuthTokenFetched(a.uid, "jwt")
+	} else {
+		metrics.AuthTokenHit(a.uid, "jwt")
 	}
 
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", a.token))
@@ -188,6 +195,7 @@ type LogshipOAuthOnBehalfOfAuth struct {
 	scope         string
 	tokens        map[string]accessToken
 	host          string
+	uid           string
 }
 
 // WithUserContext implements LogshipAuth.
@@ -239,6 +247,7 @@ func NewOAuthOnBehalfOfAuth(settings *backend.DataSourceInstanceSettings, dataso
 		tokens:        map[string]accessToken{},
 		host:          datasource.ClusterURL,
 		scope:         datasource.Scope,
+		uid:           settings.UID,
 	}, nil
 }
 
@@ -255,10 +264,14 @@ func (a *LogshipOAuthOnBehalfOfAuth) AuthenticateRequest(ctx context.Context, cl
 		delete(a.tokens, grafanaAccessToken)
 		result, err := a.authenticateOAuth(ctx, client, grafanaAccessToken)
 		if err != nil {
+			metrics.AuthTokenFailed(a.uid, "oboOAuth")
 			return err
 		}
 
+		metrics.AuthTokenFetched(a.uid, "oboOAuth")
 		a.tokens[grafanaAccessToken] = *result
+	} else {
+		metrics.AuthTokenHit(a.uid, "oboOAuth")
 	}
 
 	cached, ok = a.tokens[grafanaAccessToken]
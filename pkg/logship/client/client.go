@@ -3,16 +3,28 @@ package client
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptrace"
+	"path"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 
 	// 100% compatible drop-in replacement of "encoding/json"
 	json "github.com/json-iterator/go"
 
 	"github.com/logsink/grafana-logship-datasource/pkg/logship/client/auth"
+	"github.com/logsink/grafana-logship-datasource/pkg/logship/metrics"
 	"github.com/logsink/grafana-logship-datasource/pkg/logship/models"
 )
 
@@ -20,8 +32,9 @@ type LogshipClient interface {
 	WithUserContextFromQuery(ctx context.Context, req *backend.QueryDataRequest) (context.Context, error)
 	WithUserContextFromResource(ctx context.Context, req *backend.CallResourceRequest) (context.Context, error)
 	WithUserContextFromHealthCheck(ctx context.Context, req *backend.CheckHealthRequest) (context.Context, error)
-	TestRequest(ctx context.Context, datasourceSettings *models.DatasourceSettings, properties *models.Properties, additionalHeaders map[string]string) error
+	TestRequest(ctx context.Context, datasourceSettings *models.DatasourceSettings, properties *models.Properties, additionalHeaders map[string]string) *models.HealthReport
 	KustoRequest(ctx context.Context, url string, payload models.RequestPayload, additionalHeaders map[string]string) (*models.TableResponse, error)
+	ValidateRequest(ctx context.Context, url string, payload models.RequestPayload, additionalHeaders map[string]string) (*models.ErrorResponse, error)
 	SchemaRequest(ctx context.Context, url string, additionalHeaders map[string]string) ([]models.TableSchema, error)
 }
 
@@ -29,9 +42,10 @@ var _ LogshipClient = new(Client) // validates interface conformance
 
 // Client is an http.Client used for API requests.
 type Client struct {
-	userId     uuid.UUID
-	auth       auth.LogshipAuth
-	httpClient *http.Client
+	userId        uuid.UUID
+	auth          auth.LogshipAuth
+	httpClient    *http.Client
+	datasourceUID string
 }
 
 // NewClient creates a Grafana Plugin SDK Go Http Client
@@ -47,9 +61,10 @@ func New(instanceSettings *backend.DataSourceInstanceSettings, dsSettings *model
 	}
 
 	return &Client{
-		httpClient: httpClient,
-		userId:     uuid.Nil,
-		auth:       auth,
+		httpClient:    httpClient,
+		userId:        uuid.Nil,
+		auth:          auth,
+		datasourceUID: instanceSettings.UID,
 	}, nil
 }
 
@@ -66,22 +81,109 @@ func (c *Client) WithUserContextFromHealthCheck(ctx context.Context, req *backen
 }
 
 // TestRequest handles a data source test request in Grafana's Datasource configuration UI.
-func (c *Client) TestRequest(ctx context.Context, datasourceSettings *models.DatasourceSettings, properties *models.Properties, additionalHeaders map[string]string) error {
-	backend.Logger.Warn("Starting Logship test request:")
-	user, err := c.WhoAmIRequest(ctx, datasourceSettings.ClusterURL, additionalHeaders)
+// It checks connectivity, authentication, identity, schema access and a query round trip in turn.
+func (c *Client) TestRequest(ctx context.Context, datasourceSettings *models.DatasourceSettings, properties *models.Properties, additionalHeaders map[string]string) *models.HealthReport {
+	url := datasourceSettings.ClusterURL
+	report := &models.HealthReport{}
+
+	report.Run("connectivity", func() (string, error) {
+		return c.connectivityCheck(ctx, url)
+	})
+
+	report.Run("auth", func() (string, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+"/whoami", http.NoBody)
+		if err != nil {
+			return "", fmt.Errorf("no request instance: %w", err)
+		}
+		if err = c.authenticate(ctx, req); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("acquired %s credentials", datasourceSettings.AuthType), nil
+	})
+
+	report.Run("whoami", func() (string, error) {
+		user, err := c.WhoAmIRequest(ctx, url, additionalHeaders)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("authenticated as %s (%s)", user.UserName, user.UserID), nil
+	})
+
+	report.Run("schema", func() (string, error) {
+		tables, err := c.SchemaRequest(ctx, url, additionalHeaders)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d tables", len(tables)), nil
+	})
+
+	report.Run("query", func() (string, error) {
+		table, err := c.KustoRequest(ctx, url, models.RequestPayload{
+			Query:       "print HealthCheck = 1",
+			QuerySource: "grafana-health",
+			Properties:  properties,
+		}, additionalHeaders)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("returned %d rows", len(table.Results)), nil
+	})
+
+	return report
+}
+
+// connectivityCheck sends an unauthenticated request to Logship and reports
+// the DNS resolution, TLS handshake and HTTP status it observed.
+func (c *Client) connectivityCheck(ctx context.Context, url string) (string, error) {
+	var dnsAddrs int
+	resolved := false
+	tlsVersion := ""
+	trace := &httptrace.ClientTrace{
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			resolved = info.Err == nil
+			dnsAddrs = len(info.Addrs)
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			if err == nil {
+				tlsVersion = tlsVersionName(state.Version)
+			}
+		},
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, url, http.NoBody)
 	if err != nil {
-		backend.Logger.Error("failed to make whoami request: %w", err)
-		return err
+		return "", fmt.Errorf("invalid cluster URL %q: %w", url, err)
 	}
 
-	backend.Logger.Info("WhoAmI results user: %s", user.UserID)
-	s, err := c.SchemaRequest(ctx, datasourceSettings.ClusterURL, additionalHeaders)
+	resp, err := c.do(ctx, req)
 	if err != nil {
-		backend.Logger.Error("failed to make schema request. %w", err)
+		return "", err
 	}
+	resp.Body.Close()
 
-	backend.Logger.Info("Schema results : %s", len(s))
-	return err
+	parts := []string{}
+	if resolved {
+		parts = append(parts, fmt.Sprintf("resolved %d addresses", dnsAddrs))
+	}
+	if tlsVersion != "" {
+		parts = append(parts, tlsVersion)
+	}
+	parts = append(parts, fmt.Sprintf("HTTP %s", resp.Status))
+	return strings.Join(parts, ", "), nil
+}
+
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+	return fmt.Sprintf("TLS 0x%04x", version)
 }
 
 func (c *Client) WhoAmIRequest(ctx context.Context, url string, additionalHeaders map[string]string) (*models.WhoAmIResponse, error) {
@@ -90,9 +192,9 @@ func (c *Client) WhoAmIRequest(ctx context.Context, url string, additionalHeader
 		return nil, fmt.Errorf("no request instance: %w", err)
 	}
 
-	err = c.auth.AuthenticateRequest(ctx, c.httpClient, req)
+	err = c.authenticate(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to authenticate: %w", err)
+		return nil, models.DownstreamError(backend.StatusUnauthorized, fmt.Errorf("failed to authenticate: %w", err))
 	}
 
 	req.Header.Set("Accept", "application/json")
@@ -101,7 +203,7 @@ func (c *Client) WhoAmIRequest(ctx context.Context, url string, additionalHeader
 		req.Header.Set(key, value)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -115,7 +217,7 @@ func (c *Client) WhoAmIRequest(ctx context.Context, url string, additionalHeader
 	var user models.WhoAmIResponse
 	err = json.NewDecoder(resp.Body).Decode(&user)
 	if err != nil {
-		return nil, fmt.Errorf("HTTP %q with malformed whoami response: %s", resp.Status, err)
+		return nil, models.DownstreamError(backend.StatusBadGateway, fmt.Errorf("HTTP %q with malformed whoami response: %s", resp.Status, err))
 	}
 	return &user, nil
 }
@@ -126,9 +228,9 @@ func (c *Client) SchemaRequest(ctx context.Context, url string, additionalHeader
 		return nil, fmt.Errorf("no request instance: %w", err)
 	}
 
-	err = c.auth.AuthenticateRequest(ctx, c.httpClient, req)
+	err = c.authenticate(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to authenticate: %w", err)
+		return nil, models.DownstreamError(backend.StatusUnauthorized, fmt.Errorf("failed to authenticate: %w", err))
 	}
 
 	req.Header.Set("Accept", "application/json")
@@ -137,7 +239,7 @@ func (c *Client) SchemaRequest(ctx context.Context, url string, additionalHeader
 		req.Header.Set(key, value)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -155,6 +257,60 @@ func (c *Client) SchemaRequest(ctx context.Context, url string, additionalHeader
 // and returns a TableResponse. If there is a query syntax error, the error message inside
 // the API's JSON error response is returned as well (if available).
 func (c *Client) KustoRequest(ctx context.Context, url string, payload models.RequestPayload, additionalHeaders map[string]string) (*models.TableResponse, error) {
+	ctx, span := tracing.DefaultTracer().Start(ctx, "logship.KustoRequest", trace.WithAttributes(attribute.String("query_source", payload.QuerySource)))
+	defer span.End()
+
+	table, err := c.kustoRequest(ctx, url, payload, additionalHeaders)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return table, err
+}
+
+func (c *Client) kustoRequest(ctx context.Context, url string, payload models.RequestPayload, additionalHeaders map[string]string) (*models.TableResponse, error) {
+	resp, err := c.kustoResponse(ctx, url, payload, additionalHeaders)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	err = c.responseAsError(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	_, span := tracing.DefaultTracer().Start(ctx, "logship.TableFromJSON")
+	defer span.End()
+
+	body := &countingReader{r: resp.Body}
+	table, err := models.TableFromJSON(body)
+	span.SetAttributes(attribute.Int64("http.response_bytes", body.n))
+	if err != nil {
+		return nil, models.DownstreamError(backend.StatusBadGateway, fmt.Errorf("malformed query response: %w", err))
+	}
+	metrics.ObserveResponseBytes(c.datasourceUID, body.n)
+	return table, nil
+}
+
+// ValidateRequest executes a Kusto Query language query for its errors only.
+// When Logship rejects the query, its error response is returned instead of an error.
+func (c *Client) ValidateRequest(ctx context.Context, url string, payload models.RequestPayload, additionalHeaders map[string]string) (*models.ErrorResponse, error) {
+	resp, err := c.kustoResponse(ctx, url, payload, additionalHeaders)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	err = c.responseAsError(resp)
+	var qe *models.QueryError
+	if errors.As(err, &qe) && qe.StatusCode == http.StatusBadRequest {
+		return qe.ErrorResponse(), nil
+	}
+	return nil, err
+}
+
+func (c *Client) kustoResponse(ctx context.Context, url string, payload models.RequestPayload, additionalHeaders map[string]string) (*http.Response, error) {
 	buf, err := json.Marshal(payload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to serialize request: %w", err)
@@ -165,9 +321,9 @@ func (c *Client) KustoRequest(ctx context.Context, url string, payload models.Re
 		return nil, fmt.Errorf("no request instance: %w", err)
 	}
 
-	err = c.auth.AuthenticateRequest(ctx, c.httpClient, req)
+	err = c.authenticate(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to authenticate request to %s: %w", req.URL, err)
+		return nil, models.DownstreamError(backend.StatusUnauthorized, fmt.Errorf("failed to authenticate request to %s: %w", req.URL, err))
 	}
 
 	req.Header.Set("Accept", "application/json")
@@ -180,43 +336,66 @@ func (c *Client) KustoRequest(ctx context.Context, url string, payload models.Re
 		req.Header.Set(key, value)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	return c.do(ctx, req)
+}
+
+// authenticate adds the credentials of the configured auth type to the request.
+func (c *Client) authenticate(ctx context.Context, req *http.Request) error {
+	ctx, span := tracing.DefaultTracer().Start(ctx, "logship.Authenticate")
+	defer span.End()
+
+	err := c.auth.AuthenticateRequest(ctx, c.httpClient, req)
 	if err != nil {
-		return nil, err
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 	}
-	defer resp.Body.Close()
+	return err
+}
 
-	err = c.responseAsError(resp)
+// do sends the request to Logship, propagating the trace context with a W3C traceparent header.
+func (c *Client) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, models.TransportError(err)
 	}
-
-	return models.TableFromJSON(resp.Body)
+	return resp, nil
 }
 
 func (c *Client) responseAsError(resp *http.Response) error {
+	metrics.UpstreamResponse(c.datasourceUID, path.Base(resp.Request.URL.Path), resp.StatusCode)
+
 	switch {
 	case resp.StatusCode == http.StatusUnauthorized:
 		backend.Logger.Error("HTTP 401 Unauthorized response.", resp.Request.URL)
 		c.auth.ClearCache() // Try a re-auth
-		return fmt.Errorf("HTTP %q", resp.Status)
+		return models.NewQueryError(resp.StatusCode, resp.Status, nil)
 
 	case resp.StatusCode/100 != 2:
 		var r models.ErrorResponse
 		err := json.NewDecoder(resp.Body).Decode(&r)
 		if err != nil {
 			backend.Logger.Error("Malformed error response.", resp.StatusCode, resp.Request.URL)
-			return fmt.Errorf("HTTP %q with malformed error response: %s", resp.Status, err)
-		}
-
-		if len(r.StackTrace) > 0 {
-			// backend.Logger.Error("HTTP error response with stack.", resp.StatusCode, resp.Request.URL, r.Message, r.StackTrace)
-			return fmt.Errorf("HTTP %q with error message: %q. \nStack: %q", resp.Status, r.Message, r.StackTrace)
+			return models.NewQueryError(resp.StatusCode, resp.Status, &models.ErrorResponse{
+				Message: fmt.Sprintf("malformed error response: %s", err),
+			})
 		}
 
-		// backend.Logger.Error("HTTP error response.", resp.StatusCode, resp.Request.URL, r.Message)
-		return fmt.Errorf("HTTP %q with error message: %q", resp.Status, r.Message)
+		return models.NewQueryError(resp.StatusCode, resp.Status, &r)
 	}
 
 	return nil
 }
+
+// countingReader counts the bytes read from the underlying reader.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
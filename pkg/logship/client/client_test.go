@@ -7,8 +7,10 @@ import (
 	"os"
 	"testing"
 
+	"github.com/logsink/grafana-logship-datasource/pkg/logship/client/auth"
 	"github.com/logsink/grafana-logship-datasource/pkg/logship/models"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestClient(t *testing.T) {
@@ -92,6 +94,144 @@ func TestClient(t *testing.T) {
 	})
 }
 
+func TestValidateRequest(t *testing.T) {
+	payload := models.RequestPayload{
+		Query:       "PerfTest take 5\n| take 0",
+		QuerySource: "grafana-validate",
+	}
+
+	t.Run("When the query is valid", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(http.StatusOK)
+			_, err := rw.Write([]byte(`{"Headers":[],"Columns":[],"Results":[]}`))
+			if err != nil {
+				t.Errorf("test logic error: %s", err.Error())
+			}
+		}))
+		defer server.Close()
+
+		client := &Client{httpClient: server.Client(), auth: &auth.LogshipEmptyAuth{}}
+		errResp, err := client.ValidateRequest(context.Background(), server.URL, payload, nil)
+		require.NoError(t, err)
+		require.Nil(t, errResp)
+	})
+
+	t.Run("When the query is rejected", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(http.StatusBadRequest)
+			_, err := rw.Write([]byte(`{"message":"Syntax error","errors":[{"message":"Unexpected token 'take'","tokens":[{"start":9,"end":13}]}]}`))
+			if err != nil {
+				t.Errorf("test logic error: %s", err.Error())
+			}
+		}))
+		defer server.Close()
+
+		client := &Client{httpClient: server.Client(), auth: &auth.LogshipEmptyAuth{}}
+		errResp, err := client.ValidateRequest(context.Background(), server.URL, payload, nil)
+		require.NoError(t, err)
+		require.NotNil(t, errResp)
+		require.Equal(t, "Syntax error", errResp.Message)
+		require.Equal(t, []models.Token{{Start: 9, End: 13}}, errResp.Errors[0].Tokens)
+	})
+
+	t.Run("When the server fails", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(http.StatusInternalServerError)
+			_, err := rw.Write([]byte(`{"message":"boom"}`))
+			if err != nil {
+				t.Errorf("test logic error: %s", err.Error())
+			}
+		}))
+		defer server.Close()
+
+		client := &Client{httpClient: server.Client(), auth: &auth.LogshipEmptyAuth{}}
+		errResp, err := client.ValidateRequest(context.Background(), server.URL, payload, nil)
+		require.Error(t, err)
+		require.Nil(t, errResp)
+	})
+}
+
+func TestResponseAsError(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		message string
+		errors  []models.TokenizedErrorMessage
+		stack   string
+	}{
+		{
+			name:    "should keep tokenized errors and hide the stack trace",
+			status:  http.StatusBadRequest,
+			body:    `{"message":"Syntax error","stackTrace":"at Parser.Parse()","errors":[{"message":"Unexpected token","tokens":[{"start":2,"end":6}]}]}`,
+			message: `HTTP "400 Bad Request" with error message: "Syntax error"`,
+			errors:  []models.TokenizedErrorMessage{{Message: "Unexpected token", Tokens: []models.Token{{Start: 2, End: 6}}}},
+			stack:   "at Parser.Parse()",
+		},
+		{
+			name:    "should report malformed error responses",
+			status:  http.StatusBadGateway,
+			body:    `<html>bad gateway</html>`,
+			message: `HTTP "502 Bad Gateway" with error message: "malformed error response`,
+		},
+		{
+			name:    "should report unauthorized responses",
+			status:  http.StatusUnauthorized,
+			message: `HTTP "401 Unauthorized"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(tt.status)
+				_, err := rw.Write([]byte(tt.body))
+				if err != nil {
+					t.Errorf("test logic error: %s", err.Error())
+				}
+			}))
+			defer server.Close()
+
+			client := &Client{httpClient: server.Client(), auth: &auth.LogshipEmptyAuth{}}
+			table, err := client.KustoRequest(context.Background(), server.URL, models.RequestPayload{Query: "T"}, nil)
+			require.Nil(t, table)
+
+			var qe *models.QueryError
+			require.ErrorAs(t, err, &qe)
+			require.Equal(t, tt.status, qe.StatusCode)
+			require.Contains(t, err.Error(), tt.message)
+			require.NotContains(t, err.Error(), "Stack")
+			require.Equal(t, tt.errors, qe.Errors)
+			require.Equal(t, tt.stack, qe.StackTrace)
+		})
+	}
+}
+
+func TestTraceContextPropagation(t *testing.T) {
+	traceID := trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}
+	spanID := trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7}
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		require.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", req.Header.Get("traceparent"))
+		rw.WriteHeader(http.StatusOK)
+		_, err := rw.Write([]byte(`{"Headers":[],"Columns":[],"Results":[]}`))
+		if err != nil {
+			t.Errorf("test logic error: %s", err.Error())
+		}
+	}))
+	defer server.Close()
+
+	client := &Client{httpClient: server.Client(), auth: &auth.LogshipEmptyAuth{}}
+	table, err := client.KustoRequest(ctx, server.URL, models.RequestPayload{Query: "T"}, nil)
+	require.NoError(t, err)
+	require.NotNil(t, table)
+}
+
 func loadTestFile(path string) ([]byte, error) {
 	jsonBody, err := os.ReadFile(path)
 	if err != nil {
@@ -1,16 +1,24 @@
 package logship
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/logsink/grafana-logship-datasource/pkg/logship/client"
+	"github.com/logsink/grafana-logship-datasource/pkg/logship/metrics"
 	"github.com/logsink/grafana-logship-datasource/pkg/logship/models"
 
 	// 100% compatible drop-in replacement of "encoding/json"
@@ -23,6 +31,8 @@ type LogshipBackend struct {
 	backend.CallResourceHandler
 	client   client.LogshipClient
 	settings *models.DatasourceSettings
+	uid      string
+	schemas  *schemaCache
 }
 
 func NewDatasource(ctx context.Context, instanceSettings backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
@@ -41,12 +51,14 @@ func NewDatasource(ctx context.Context, instanceSettings backend.DataSourceInsta
 	}
 
 	logship.settings = datasourceSettings
+	logship.uid = instanceSettings.UID
 	logshipClient, err := client.New(&instanceSettings, datasourceSettings)
 	if err != nil {
 		backend.Logger.Error("failed to create Logship client", "error", err.Error())
 		return nil, err
 	}
 	logship.client = logshipClient
+	logship.schemas = newSchemaCache(instanceSettings.UID, datasourceSettings.SchemaCacheTTL, logship.fetchSchema)
 
 	mux := http.NewServeMux()
 	logship.registerRoutes(mux)
@@ -57,16 +69,42 @@ func NewDatasource(ctx context.Context, instanceSettings backend.DataSourceInsta
 
 // QueryData is the primary method called by grafana-server
 func (logship *LogshipBackend) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	ctx, span := tracing.DefaultTracer().Start(ctx, "logship.QueryData", trace.WithAttributes(
+		attribute.String("datasource_uid", logship.uid),
+		attribute.Int("queries", len(req.Queries)),
+	))
+	defer span.End()
+
 	ctx, err := logship.client.WithUserContextFromQuery(ctx, req)
 	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return nil, err
 	}
 
 	backend.Logger.Info("Query", "datasource", req.PluginContext.DataSourceInstanceSettings.Name)
 
-	res := backend.NewQueryDataResponse()
+	// Queries referencing other queries with $__result run after the queries they depend on,
+	// including references made from the bodies of saved functions.
+	queries := map[string]backend.DataQuery{}
+	refIDs := make([]string, 0, len(req.Queries))
+	dependencies := map[string][]string{}
 	for _, q := range req.Queries {
-		res.Responses[q.RefID] = logship.handleQuery(ctx, q, req.PluginContext.User)
+		var qm models.QueryModel
+		if err := json.Unmarshal(q.JSON, &qm); err == nil && qm.ExpandFunctions(logship.settings.QueryFunctions) == nil {
+			dependencies[q.RefID] = models.ResultDependencies(qm.Query)
+		}
+		queries[q.RefID] = q
+		refIDs = append(refIDs, q.RefID)
+	}
+
+	res := backend.NewQueryDataResponse()
+	order, errs := models.SortByDependencies(refIDs, dependencies)
+	for refID, err := range errs {
+		res.Responses[refID] = models.ErrorDataResponse(models.PluginError(backend.StatusBadRequest, err))
+	}
+	for _, refID := range order {
+		res.Responses[refID] = logship.handleQuery(ctx, queries[refID], req.PluginContext.User, res.Responses)
 	}
 
 	return res, nil
@@ -90,46 +128,168 @@ func (logship *LogshipBackend) CheckHealth(ctx context.Context, req *backend.Che
 	headers := map[string]string{}
 	backend.Logger.Info("Checking logship health.")
 
-	err = logship.client.TestRequest(ctx, logship.settings, models.NewConnectionProperties(logship.settings, nil), headers)
+	report := logship.client.TestRequest(ctx, logship.settings, models.NewConnectionProperties(logship.settings, nil), headers)
+	details, err := json.Marshal(report)
 	if err != nil {
-		backend.Logger.Error("could not complete test request. %w", err)
+		return nil, fmt.Errorf("failed to serialize health report: %w", err)
+	}
+
+	if err := report.Err(); err != nil {
+		backend.Logger.Error("could not complete test request", "error", err)
 		return &backend.CheckHealthResult{
-			Status:  backend.HealthStatusError,
-			Message: err.Error(),
+			Status:      backend.HealthStatusError,
+			Message:     err.Error(),
+			JSONDetails: details,
 		}, nil
 	}
 
 	return &backend.CheckHealthResult{
-		Status:  backend.HealthStatusOk,
-		Message: "Success",
+		Status:      backend.HealthStatusOk,
+		Message:     "Success",
+		JSONDetails: details,
 	}, nil
 }
 
-func (logship *LogshipBackend) handleQuery(ctx context.Context, q backend.DataQuery, user *backend.User) backend.DataResponse {
+func (logship *LogshipBackend) handleQuery(ctx context.Context, q backend.DataQuery, user *backend.User, results backend.Responses) backend.DataResponse {
+	ctx, span := tracing.DefaultTracer().Start(ctx, "logship.handleQuery", trace.WithAttributes(attribute.String("ref_id", q.RefID)))
+	defer span.End()
+
+	resp := logship.executeQuery(ctx, q, user, results)
+	if resp.Error != nil {
+		span.RecordError(resp.Error)
+		span.SetStatus(codes.Error, resp.Error.Error())
+	}
+	return resp
+}
+
+func (logship *LogshipBackend) executeQuery(ctx context.Context, q backend.DataQuery, user *backend.User, results backend.Responses) backend.DataResponse {
 	var qm models.QueryModel
 	err := json.Unmarshal(q.JSON, &qm)
 	if err != nil {
-		return backend.DataResponse{Error: fmt.Errorf("malformed request query: %w", err)}
+		return models.ErrorDataResponse(models.PluginError(backend.StatusBadRequest, fmt.Errorf("malformed request query: %w", err)))
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("query_source", querySourceOrDefault(qm.QuerySource)),
+		attribute.String("format", formatOrDefault(qm.Format)),
+	)
+
+	if qm.QueryType == models.QueryTypeFunction {
+		if qm.Query, err = qm.FunctionCall(); err != nil {
+			return models.ErrorDataResponse(models.PluginError(backend.StatusBadRequest, err))
+		}
+	}
+
+	if err := qm.ExpandFunctions(logship.settings.QueryFunctions); err != nil {
+		return models.ErrorDataResponse(models.PluginError(backend.StatusBadRequest, err))
+	}
+	if err := qm.InterpolateResults(results); err != nil {
+		return models.ErrorDataResponse(models.PluginError(backend.StatusBadRequest, err))
+	}
+
+	if err := qm.FrameOptions.Validate(); err != nil {
+		return models.ErrorDataResponse(models.PluginError(backend.StatusBadRequest, err))
+	}
+	shift, err := models.ParseTimeShift(qm.TimeShift)
+	if err != nil {
+		return models.ErrorDataResponse(models.PluginError(backend.StatusBadRequest, err))
+	}
+	// spans start at absolute times, which the trace view shows as they are
+	if shift != nil && qm.QueryType != models.QueryTypeAnnotations && formatOrDefault(qm.Format) == "trace" {
+		return models.ErrorDataResponse(models.PluginError(backend.StatusBadRequest, fmt.Errorf("timeShift is not supported by the trace format")))
 	}
 
 	cs := models.NewCacheSettings(logship.settings, &q, &qm)
-	qm.MacroData = models.NewMacroData(cs.TimeRange, q.Interval.Milliseconds())
-	if err := qm.Interpolate(); err != nil {
-		return backend.DataResponse{Error: err}
+	timeRange := cs.TimeRange
+	if shift != nil {
+		timeRange = shift.ShiftTimeRange(timeRange)
+	}
+
+	qm.MacroData = models.NewMacroData(timeRange, q.Interval.Milliseconds())
+	_, interpolateSpan := tracing.DefaultTracer().Start(ctx, "logship.Interpolate")
+	err = qm.Interpolate()
+	interpolateSpan.End()
+	if err != nil {
+		return models.ErrorDataResponse(models.PluginError(backend.StatusBadRequest, err))
+	}
+	if err := logship.enforceSchemaMapping(ctx, qm, user); err != nil {
+		return models.ErrorDataResponse(err)
 	}
 	props := models.NewConnectionProperties(logship.settings, cs)
 
+	start := time.Now()
 	resp, err := logship.modelQuery(ctx, qm, props, user)
+	metrics.ObserveQuery(logship.uid, querySourceOrDefault(qm.QuerySource), formatOrDefault(qm.Format), time.Since(start))
 	if err != nil {
-		resp.Frames = append(resp.Frames, &data.Frame{
+		frame := &data.Frame{
 			RefID: q.RefID,
 			Meta:  &data.FrameMeta{ExecutedQueryString: qm.Query},
-		})
+		}
+
+		var qe *models.QueryError
+		if errors.As(err, &qe) {
+			frame.Meta.Custom = models.LogshipFrameMD{QueryErrors: qe.Errors}
+			if logship.settings.ShowStackTrace && qe.StackTrace != "" {
+				err = fmt.Errorf("%w. \nStack: %q", err, qe.StackTrace)
+			}
+		}
+
+		resp.Frames = append(resp.Frames, frame)
 		resp.Error = err
+		resp.Status, resp.ErrorSource = models.ClassifyError(err)
+		return resp
+	}
+
+	if shift != nil {
+		shiftFrames(shift, qm, resp.Frames)
 	}
 	return resp
 }
 
+// shiftFrames moves the frames of the response back by the time shift. Only series
+// are labelled with the shift, the fields of structured formats keep the names the
+// visualizations look them up by.
+func shiftFrames(shift *models.TimeShift, qm models.QueryModel, frames data.Frames) {
+	format := formatOrDefault(qm.Format)
+	if qm.QueryType == models.QueryTypeAnnotations || format == "node_graph" || format == "heatmap" {
+		shift.ShiftTimes(frames)
+		return
+	}
+	shift.ShiftFrames(frames)
+}
+
+// enforceSchemaMapping rejects queries that reference tables, materialized views or
+// stored functions which are not mapped, when the datasource enforces its schema
+// mappings. Queries whose references cannot be resolved are rejected as well.
+func (logship *LogshipBackend) enforceSchemaMapping(ctx context.Context, qm models.QueryModel, user *backend.User) error {
+	if logship.settings.Mappings == nil || !logship.settings.EnforceSchemaMapping {
+		return nil
+	}
+
+	entry, err := logship.schemas.Get(ctx, logship.schemaCacheKey(user), false)
+	if err != nil {
+		return fmt.Errorf("failed to load the schema to enforce schema mappings: %w", err)
+	}
+
+	database := logship.settings.SchemaDatabase(qm.Database)
+	if qm.QueryType == models.QueryTypeFunction && !logship.settings.Mappings.Maps(database, qm.Function, models.SchemaMappingFunction) {
+		return models.PluginError(backend.StatusForbidden,
+			fmt.Errorf("function %q is not mapped in the datasource settings", qm.Function))
+	}
+	unmapped, err := logship.settings.Mappings.UnmappedReferences(qm.Query, database, entry.Schema, func(database string) ([]models.FunctionSchema, error) {
+		return entry.Functions.Get(ctx, database, logship.fetchStoredFunctions)
+	})
+	if err != nil {
+		return models.PluginError(backend.StatusForbidden,
+			fmt.Errorf("query cannot be checked against the schema mappings of the datasource settings: %w", err))
+	}
+	if len(unmapped) > 0 {
+		return models.PluginError(backend.StatusForbidden,
+			fmt.Errorf("query references tables or functions that are not mapped in the datasource settings: %s", strings.Join(unmapped, ", ")))
+	}
+	return nil
+}
+
 func (logship *LogshipBackend) modelQuery(ctx context.Context, q models.QueryModel, props *models.Properties, user *backend.User) (backend.DataResponse, error) {
 	headers := map[string]string{}
 	if logship.settings.EnableUserTracking {
@@ -142,6 +302,7 @@ func (logship *LogshipBackend) modelQuery(ctx context.Context, q models.QueryMod
 		Query:       q.Query,
 		Properties:  props,
 		QuerySource: q.QuerySource,
+		Database:    logship.settings.DatabaseOrDefault(q.Database),
 	}, headers)
 
 	if err != nil {
@@ -149,18 +310,52 @@ func (logship *LogshipBackend) modelQuery(ctx context.Context, q models.QueryMod
 		return backend.DataResponse{}, err
 	}
 
-	if q.Format == "" {
-		q.Format = "table"
-	}
+	q.Format = formatOrDefault(q.Format)
+	q.Int128AsString = logship.settings.Int128AsString
+	q.TypeMappings = logship.settings.TypeMappings
+	metrics.ObserveResponseRows(logship.uid, q.Format, len(tableRes.Results))
 
 	var resp backend.DataResponse
+	if q.QueryType == models.QueryTypeVariable {
+		values, err := models.VariableValues(tableRes, q.VariableOptions)
+		if err != nil {
+			return resp, models.PluginError(backend.StatusBadRequest, err)
+		}
+		resp.Frames = data.Frames{models.VariableFrame(values)}
+		return resp, nil
+	}
+	if q.QueryType == models.QueryTypeAnnotations {
+		resp.Frames, err = reshapeFrame(tableRes, q, func(frame *data.Frame) (data.Frames, error) {
+			frame, err := models.AnnotationFrame(frame, q.AnnotationOptions)
+			return data.Frames{frame}, err
+		})
+		return resp, err
+	}
+
+	_, convertSpan := tracing.DefaultTracer().Start(ctx, "logship.ToDataFrames", trace.WithAttributes(attribute.Int("rows", len(tableRes.Results))))
+	defer convertSpan.End()
+
 	switch q.Format {
 	case "table":
-		resp.Frames, err = tableRes.ToDataFrames(q.Query)
+		resp.Frames, err = tableRes.ToDataFrames(q.Query, q.FrameOptions)
 		if err != nil {
 			backend.Logger.Debug("error converting response to data frames", "error", err.Error())
-			return resp, fmt.Errorf("error converting response to data frames: %w", err)
+			return resp, models.PluginError(backend.StatusInternal, fmt.Errorf("error converting response to data frames: %w", err))
 		}
+	case "trace":
+		resp.Frames, err = reshapeFrame(tableRes, q, func(frame *data.Frame) (data.Frames, error) {
+			frame, err := models.TraceFrame(frame, q.TraceOptions)
+			return data.Frames{frame}, err
+		})
+	case "node_graph":
+		resp.Frames, err = reshapeFrame(tableRes, q, func(frame *data.Frame) (data.Frames, error) {
+			return models.NodeGraphFrames(frame, q.NodeGraphOptions)
+		})
+	case "heatmap":
+		resp.Frames, err = reshapeFrame(tableRes, q, func(frame *data.Frame) (data.Frames, error) {
+			frame, err := models.HeatmapFrame(frame, q.HeatmapOptions)
+			return data.Frames{frame}, err
+		})
 	case "time_series":
 		index := -1
 		for i, t := range tableRes.Columns {
@@ -179,9 +374,9 @@ func (logship *LogshipBackend) modelQuery(ctx context.Context, q models.QueryMod
 			})
 		}
 
-		frames, err := tableRes.ToDataFrames(q.Query)
+		frames, err := tableRes.ToDataFrames(q.Query, q.FrameOptions)
 		if err != nil {
-			return resp, err
+			return resp, models.PluginError(backend.StatusInternal, fmt.Errorf("error converting response to data frames: %w", err))
 		}
 
 		if len(tableRes.Columns) <= 2 {
@@ -209,8 +404,37 @@ func (logship *LogshipBackend) modelQuery(ctx context.Context, q models.QueryMod
 		}
 
 	default:
-		resp.Error = fmt.Errorf("unsupported query type: '%v'", q.Format)
+		return resp, models.PluginError(backend.StatusBadRequest, fmt.Errorf("unsupported query type: '%v'", q.Format))
+	}
+
+	return resp, err
+}
+
+// reshapeFrame converts the response into a data frame and reshapes it into the
+// frames of a structured format, e.g. annotations or traces. Errors reshaping the
+// frame come from the options or columns of the query.
+func reshapeFrame(tableRes *models.TableResponse, q models.QueryModel, reshape func(*data.Frame) (data.Frames, error)) (data.Frames, error) {
+	frames, err := tableRes.ToDataFrames(q.Query, q.FrameOptions)
+	if err != nil {
+		return nil, models.PluginError(backend.StatusInternal, fmt.Errorf("error converting response to data frames: %w", err))
 	}
+	reshaped, err := reshape(frames[0])
+	if err != nil {
+		return nil, models.PluginError(backend.StatusBadRequest, err)
+	}
+	return reshaped, nil
+}
 
-	return resp, nil
+func formatOrDefault(format string) string {
+	if format == "" {
+		return "table"
+	}
+	return format
+}
+
+func querySourceOrDefault(querySource string) string {
+	if querySource == "" {
+		return "unspecified"
+	}
+	return querySource
 }
@@ -0,0 +1,346 @@
+package logship
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/logsink/grafana-logship-datasource/pkg/logship/client"
+	"github.com/logsink/grafana-logship-datasource/pkg/logship/models"
+)
+
+// fakeClient answers Kusto requests with the JSON response of the query and
+// records the payloads and headers of the requests.
+type fakeClient struct {
+	client.LogshipClient
+	responses map[string]string
+	payloads  []models.RequestPayload
+	headers   []map[string]string
+}
+
+func (c *fakeClient) WithUserContextFromQuery(ctx context.Context, req *backend.QueryDataRequest) (context.Context, error) {
+	return ctx, nil
+}
+
+func (c *fakeClient) KustoRequest(ctx context.Context, url string, payload models.RequestPayload, additionalHeaders map[string]string) (*models.TableResponse, error) {
+	c.payloads = append(c.payloads, payload)
+	c.headers = append(c.headers, additionalHeaders)
+	for query, response := range c.responses {
+		if strings.Contains(payload.Query, query) {
+			return models.TableFromJSON(strings.NewReader(response))
+		}
+	}
+	return models.TableFromJSON(strings.NewReader(`{"Columns": [], "Results": []}`))
+}
+
+// resourceRecorder records the response of a resource call.
+type resourceRecorder struct {
+	*backend.CallResourceResponse
+}
+
+func (r *resourceRecorder) Send(res *backend.CallResourceResponse) error {
+	r.CallResourceResponse = res
+	return nil
+}
+
+// newTestBackend returns a backend that queries the fake client with the settings.
+func newTestBackend(t *testing.T, settings *models.DatasourceSettings, fake *fakeClient) *LogshipBackend {
+	t.Helper()
+	require.NoError(t, settings.Load(backend.DataSourceInstanceSettings{}))
+	logship := &LogshipBackend{client: fake, settings: settings, uid: "uid"}
+	logship.schemas = newSchemaCache("uid", settings.SchemaCacheTTL, logship.fetchSchema)
+	return logship
+}
+
+// queryData runs the queries, given by RefID as query model JSON.
+func queryData(t *testing.T, logship *LogshipBackend, queries ...backend.DataQuery) *backend.QueryDataResponse {
+	t.Helper()
+	now := time.Date(2023, 1, 8, 12, 0, 0, 0, time.UTC)
+	for i := range queries {
+		queries[i].TimeRange = backend.TimeRange{From: now.Add(-time.Hour), To: now}
+		queries[i].Interval = time.Minute
+	}
+	res, err := logship.QueryData(context.Background(), &backend.QueryDataRequest{
+		PluginContext: backend.PluginContext{DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{Name: "Logship"}},
+		Queries:       queries,
+	})
+	require.NoError(t, err)
+	return res
+}
+
+func TestQueryData_ResultsInFunctions(t *testing.T) {
+	fake := &fakeClient{responses: map[string]string{
+		"Hosts": `{"Columns": [{"Name": "Host", "Type": "String"}], "Results": [{"Host": "a"}, {"Host": "b"}]}`,
+	}}
+	logship := newTestBackend(t, &models.DatasourceSettings{
+		QueryFunctions: []models.QueryFunction{{Name: "byHost", Parameters: []string{"ref"}, Body: `Requests | where Host in ($__result({ref}, Host))`}},
+	}, fake)
+
+	res := queryData(t, logship,
+		backend.DataQuery{RefID: "B", JSON: []byte(`{"query": "$__fn(byHost, A) | count"}`)},
+		backend.DataQuery{RefID: "A", JSON: []byte(`{"query": "Hosts"}`)},
+	)
+
+	require.NoError(t, res.Responses["A"].Error)
+	require.NoError(t, res.Responses["B"].Error)
+	require.Len(t, fake.payloads, 2)
+	require.Equal(t, "Hosts", fake.payloads[0].Query)
+	require.Equal(t, `Requests | where Host in (dynamic(["a", "b"])) | count`, fake.payloads[1].Query)
+}
+
+func TestSchemaMappingEnforcement(t *testing.T) {
+	newBackend := func(t *testing.T, fake *fakeClient) *LogshipBackend {
+		return newTestBackend(t, &models.DatasourceSettings{
+			UseSchemaMapping:     true,
+			EnforceSchemaMapping: true,
+			SchemaMappings: []models.SchemaMapping{
+				{Type: models.SchemaMappingTable, Value: "Logs", Name: "Logs", Database: "Default", DisplayName: "Logs"},
+			},
+		}, fake)
+	}
+
+	t.Run("queries referencing unmapped tables are forbidden", func(t *testing.T) {
+		fake := &fakeClient{}
+		res := queryData(t, newBackend(t, fake),
+			backend.DataQuery{RefID: "A", JSON: []byte(`{"query": "Logs | where Secrets > 0"}`)},
+			backend.DataQuery{RefID: "B", JSON: []byte(`{"query": "Logs | join (database(\"archive\").Secrets) on Id"}`)},
+			backend.DataQuery{RefID: "C", JSON: []byte(`{"query": "table(strcat(\"Sec\", \"rets\"))"}`)},
+		)
+
+		require.NoError(t, res.Responses["A"].Error)
+		require.ErrorContains(t, res.Responses["B"].Error, "archive.Secrets")
+		require.Equal(t, backend.StatusForbidden, res.Responses["B"].Status)
+		require.ErrorContains(t, res.Responses["C"].Error, "cannot be resolved")
+		require.Equal(t, backend.StatusForbidden, res.Responses["C"].Status)
+	})
+
+	t.Run("validation rejects unmapped tables", func(t *testing.T) {
+		fake := &fakeClient{}
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/validate", strings.NewReader(`{"query": "Secrets | take 1"}`))
+
+		newBackend(t, fake).validateQuery(rw, req)
+
+		require.Equal(t, http.StatusForbidden, rw.Code)
+		require.Contains(t, rw.Body.String(), "Secrets")
+	})
+}
+
+func TestGetFunctions_CachesTheCatalog(t *testing.T) {
+	fake := &fakeClient{responses: map[string]string{
+		models.FunctionCatalogQuery: `{"Columns": [{"Name": "Name", "Type": "String"}], "Results": [{"Name": "Errors"}]}`,
+	}}
+	logship := newTestBackend(t, &models.DatasourceSettings{SchemaCacheTTLRaw: "5m"}, fake)
+
+	for i := 0; i < 2; i++ {
+		rw := httptest.NewRecorder()
+		logship.getFunctions(rw, httptest.NewRequest("GET", "/functions?database=archive", nil))
+		require.Equal(t, http.StatusOK, rw.Code)
+		require.Contains(t, rw.Body.String(), `"Errors"`)
+	}
+
+	catalogRequests := []models.RequestPayload{}
+	for _, payload := range fake.payloads {
+		if payload.Query == models.FunctionCatalogQuery {
+			catalogRequests = append(catalogRequests, payload)
+		}
+	}
+	require.Len(t, catalogRequests, 1)
+	require.Equal(t, "archive", catalogRequests[0].Database)
+}
+
+func TestGetVariableValues(t *testing.T) {
+	fake := &fakeClient{responses: map[string]string{
+		"Hosts": `{"Columns": [{"Name": "Host", "Type": "String"}, {"Name": "Id", "Type": "String"}], "Results": [{"Host": "web-2", "Id": "2"}, {"Host": "web-1", "Id": "1"}, {"Host": "db-1", "Id": "3"}]}`,
+	}}
+	logship := newTestBackend(t, &models.DatasourceSettings{EnableUserTracking: true}, fake)
+
+	res := &resourceRecorder{}
+	err := httpadapter.New(http.HandlerFunc(logship.getVariableValues)).CallResource(context.Background(), &backend.CallResourceRequest{
+		PluginContext: backend.PluginContext{User: &backend.User{Login: "alice"}},
+		Method:        "POST",
+		Path:          "variables",
+		Body:          []byte(`{"query": "Hosts", "database": "archive", "regex": "/^web/", "sort": "desc"}`),
+	}, res)
+
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.Status)
+	require.JSONEq(t, `[{"text": "web-2", "value": "web-2"}, {"text": "web-1", "value": "web-1"}]`, string(res.Body))
+	require.Equal(t, "archive", fake.payloads[len(fake.payloads)-1].Database)
+	require.Equal(t, map[string]string{"x-logship-user-id": "alice"}, fake.headers[len(fake.headers)-1])
+}
+
+func TestQueryData_AnnotationsWithTimeShift(t *testing.T) {
+	fake := &fakeClient{responses: map[string]string{
+		"Deployments": `{"Columns": [{"Name": "Timestamp", "Type": "DateTime"}, {"Name": "Title", "Type": "String"}, {"Name": "Count", "Type": "Int64"}],
+			"Results": [{"Timestamp": "2023-01-01T11:30:00Z", "Title": "deploy", "Count": 3}]}`,
+	}}
+	logship := newTestBackend(t, &models.DatasourceSettings{}, fake)
+
+	res := queryData(t, logship, backend.DataQuery{RefID: "A", JSON: []byte(`{"query": "Deployments", "queryType": "annotations", "timeShift": "-7d"}`)})
+
+	require.NoError(t, res.Responses["A"].Error)
+	frame := res.Responses["A"].Frames[0]
+	times, _ := frame.FieldByName("time")
+	require.NotNil(t, times)
+	require.Equal(t, time.Date(2023, 1, 8, 11, 30, 0, 0, time.UTC), times.At(0))
+	for _, field := range frame.Fields {
+		require.True(t, field.Config == nil || field.Config.DisplayName == "", "field %s is labelled", field.Name)
+	}
+}
+
+func TestQueryData_TraceRejectsTimeShift(t *testing.T) {
+	fake := &fakeClient{}
+	logship := newTestBackend(t, &models.DatasourceSettings{}, fake)
+
+	res := queryData(t, logship, backend.DataQuery{RefID: "A", JSON: []byte(`{"query": "Spans", "resultFormat": "trace", "timeShift": "-1d"}`)})
+
+	require.ErrorContains(t, res.Responses["A"].Error, "timeShift is not supported by the trace format")
+	require.Equal(t, backend.StatusBadRequest, res.Responses["A"].Status)
+	require.Empty(t, fake.payloads)
+}
+
+func TestQueryData_Formats(t *testing.T) {
+	calls := `{"Columns": [{"Name": "Caller", "Type": "String"}, {"Name": "Callee", "Type": "String"}, {"Name": "count_", "Type": "Int64"}],
+		"Results": [{"Caller": "web", "Callee": "api", "count_": 3}, {"Caller": null, "Callee": "db", "count_": 1}, {"Caller": "api", "Callee": "db", "count_": null}]}`
+	noCalls := `{"Columns": [{"Name": "Caller", "Type": "String"}, {"Name": "Callee", "Type": "String"}, {"Name": "count_", "Type": "Int64"}], "Results": []}`
+	spans := `{"Columns": [{"Name": "TraceId", "Type": "String"}, {"Name": "SpanId", "Type": "String"}, {"Name": "ParentId", "Type": "String"}, {"Name": "Name", "Type": "String"}, {"Name": "Service", "Type": "String"}, {"Name": "StartTime", "Type": "DateTime"}, {"Name": "Duration", "Type": "TimeSpan"}],
+		"Results": [{"TraceId": "t1", "SpanId": "a", "ParentId": null, "Name": "GET /", "Service": "web", "StartTime": "2023-01-08T11:00:00Z", "Duration": "00:00:00.0025"}]}`
+	latencies := `{"Columns": [{"Name": "Timestamp", "Type": "DateTime"}, {"Name": "Latency", "Type": "Float64"}, {"Name": "count_", "Type": "Int64"}],
+		"Results": [{"Timestamp": "2023-01-07T11:00:00Z", "Latency": 0, "count_": 2}, {"Timestamp": "2023-01-07T11:00:00Z", "Latency": 10, "count_": null}, {"Timestamp": null, "Latency": 10, "count_": 1}]}`
+	noEvents := `{"Columns": [{"Name": "Timestamp", "Type": "DateTime"}, {"Name": "Message", "Type": "String"}], "Results": []}`
+
+	tests := []struct {
+		name     string
+		query    string
+		response string
+		errorIs  assert.ErrorAssertionFunc
+		check    func(t *testing.T, frames data.Frames)
+	}{
+		{
+			name:     "node graph with nulls",
+			query:    `{"query": "Calls", "resultFormat": "node_graph"}`,
+			response: calls,
+			errorIs:  assert.NoError,
+			check: func(t *testing.T, frames data.Frames) {
+				require.Len(t, frames, 2)
+				require.Equal(t, 2, frames[1].Rows())
+			},
+		},
+		{
+			name:     "node graph without rows",
+			query:    `{"query": "Calls", "resultFormat": "node_graph"}`,
+			response: noCalls,
+			errorIs:  assert.NoError,
+			check: func(t *testing.T, frames data.Frames) {
+				require.Len(t, frames, 2)
+				require.Equal(t, 0, frames[0].Rows())
+				require.Equal(t, 0, frames[1].Rows())
+			},
+		},
+		{
+			name:     "node graph with a time shift",
+			query:    `{"query": "Calls", "resultFormat": "node_graph", "timeShift": "-1d"}`,
+			response: calls,
+			errorIs:  assert.NoError,
+			check: func(t *testing.T, frames data.Frames) {
+				for _, frame := range frames {
+					mainStat, _ := frame.FieldByName("mainstat")
+					require.Equal(t, "count_", mainStat.Config.DisplayName)
+				}
+			},
+		},
+		{
+			name:     "trace with nulls",
+			query:    `{"query": "Spans", "resultFormat": "trace"}`,
+			response: spans,
+			errorIs:  assert.NoError,
+			check: func(t *testing.T, frames data.Frames) {
+				require.Len(t, frames, 1)
+				parent, _ := frames[0].FieldByName("parentSpanID")
+				require.Nil(t, parent.At(0))
+			},
+		},
+		{
+			name:     "trace without rows",
+			query:    `{"query": "Spans", "resultFormat": "trace"}`,
+			response: strings.Replace(spans, `{"TraceId": "t1", "SpanId": "a", "ParentId": null, "Name": "GET /", "Service": "web", "StartTime": "2023-01-08T11:00:00Z", "Duration": "00:00:00.0025"}`, "", 1),
+			errorIs:  assert.NoError,
+			check: func(t *testing.T, frames data.Frames) {
+				require.Equal(t, 0, frames[0].Rows())
+			},
+		},
+		{
+			name:     "heatmap with a time shift",
+			query:    `{"query": "Latencies", "resultFormat": "heatmap", "timeShift": "-1d"}`,
+			response: latencies,
+			errorIs:  assert.NoError,
+			check: func(t *testing.T, frames data.Frames) {
+				xMin, _ := frames[0].FieldByName("xMin")
+				require.Equal(t, time.Date(2023, 1, 8, 11, 0, 0, 0, time.UTC), xMin.At(0))
+				count, _ := frames[0].FieldByName("count")
+				require.Nil(t, count.Config)
+				yMin, _ := frames[0].FieldByName("yMin")
+				require.Equal(t, "Latency", yMin.Config.DisplayName)
+			},
+		},
+		{
+			name:     "heatmap without rows",
+			query:    `{"query": "Latencies", "resultFormat": "heatmap", "bucketSize": 10}`,
+			response: strings.SplitAfter(latencies, `"Results": `)[0] + "[]}",
+			errorIs:  assert.NoError,
+			check: func(t *testing.T, frames data.Frames) {
+				require.Equal(t, 0, frames[0].Rows())
+			},
+		},
+		{
+			name:     "annotations without rows",
+			query:    `{"query": "Events", "queryType": "annotations"}`,
+			response: noEvents,
+			errorIs:  assert.NoError,
+			check: func(t *testing.T, frames data.Frames) {
+				require.Equal(t, 0, frames[0].Rows())
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeClient{responses: map[string]string{"": tt.response}}
+			logship := newTestBackend(t, &models.DatasourceSettings{}, fake)
+
+			res := queryData(t, logship, backend.DataQuery{RefID: "A", JSON: []byte(tt.query)})
+
+			tt.errorIs(t, res.Responses["A"].Error)
+			if tt.check != nil {
+				tt.check(t, res.Responses["A"].Frames)
+			}
+		})
+	}
+}
+
+func TestQueryData_InvalidFrameOptions(t *testing.T) {
+	fake := &fakeClient{}
+	logship := newTestBackend(t, &models.DatasourceSettings{}, fake)
+
+	res := queryData(t, logship,
+		backend.DataQuery{RefID: "A", JSON: []byte(`{"query": "Places", "geoMode": "wkt"}`)},
+		backend.DataQuery{RefID: "B", JSON: []byte(`{"query": "Places", "geoMode": "geohash", "geohashPrecision": 13}`)},
+		backend.DataQuery{RefID: "C", JSON: []byte(`{"query": "Places", "flattenDepth": -1}`)},
+	)
+
+	for _, refID := range []string{"A", "B", "C"} {
+		require.Error(t, res.Responses[refID].Error)
+		require.Equal(t, backend.StatusBadRequest, res.Responses[refID].Status)
+	}
+	require.Empty(t, fake.payloads)
+}
@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	namespace = "grafana_plugin"
+	subsystem = "logship"
+)
+
+// Metrics are registered with the default prometheus registry, which the plugin SDK
+// exposes through Grafana's plugin metrics endpoint.
+var (
+	queryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "query_duration_seconds",
+		Help:      "Duration of Logship queries, including conversion to data frames.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+	}, []string{"datasource_uid", "query_source", "format"})
+
+	responseRows = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "query_response_rows",
+		Help:      "Number of rows returned by Logship queries.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 12),
+	}, []string{"datasource_uid", "format"})
+
+	responseBytes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "query_response_bytes",
+		Help:      "Size of the response bodies of Logship queries.",
+		Buckets:   prometheus.ExponentialBuckets(256, 4, 12),
+	}, []string{"datasource_uid"})
+
+	authTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "auth_tokens_total",
+		Help:      "Auth token lookups by auth type and result: hit, fetch or failure.",
+	}, []string{"datasource_uid", "auth_type", "result"})
+
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "cache_requests_total",
+		Help:      "Plugin cache lookups by cache and result: hit or miss.",
+	}, []string{"datasource_uid", "cache", "result"})
+
+	upstreamResponses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "upstream_responses_total",
+		Help:      "HTTP responses received from Logship by endpoint and status code.",
+	}, []string{"datasource_uid", "endpoint", "status_code"})
+)
+
+func init() {
+	prometheus.MustRegister(queryDuration, responseRows, responseBytes, authTokens, cacheRequests, upstreamResponses)
+}
+
+// ObserveQuery records the duration of a query.
+func ObserveQuery(uid string, querySource string, format string, d time.Duration) {
+	queryDuration.WithLabelValues(uid, querySource, format).Observe(d.Seconds())
+}
+
+// ObserveResponseRows records the number of rows returned by a query.
+func ObserveResponseRows(uid string, format string, rows int) {
+	responseRows.WithLabelValues(uid, format).Observe(float64(rows))
+}
+
+// ObserveResponseBytes records the size of a query response body.
+func ObserveResponseBytes(uid string, bytes int64) {
+	responseBytes.WithLabelValues(uid).Observe(float64(bytes))
+}
+
+// AuthTokenHit records an auth token served from the token cache.
+func AuthTokenHit(uid string, authType string) {
+	authTokens.WithLabelValues(uid, authType, "hit").Inc()
+	CacheHit(uid, "auth_token")
+}
+
+// AuthTokenFetched records an auth token fetched from the token provider.
+func AuthTokenFetched(uid string, authType string) {
+	authTokens.WithLabelValues(uid, authType, "fetch").Inc()
+	CacheMiss(uid, "auth_token")
+}
+
+// AuthTokenFailed records a failure to fetch an auth token.
+func AuthTokenFailed(uid string, authType string) {
+	authTokens.WithLabelValues(uid, authType, "failure").Inc()
+	CacheMiss(uid, "auth_token")
+}
+
+// CacheHit records a lookup that was served from the named cache.
+func CacheHit(uid string, cache string) {
+	cacheRequests.WithLabelValues(uid, cache, "hit").Inc()
+}
+
+// CacheMiss records a lookup that was not served from the named cache.
+func CacheMiss(uid string, cache string) {
+	cacheRequests.WithLabelValues(uid, cache, "miss").Inc()
+}
+
+// UpstreamResponse records the status code of a response from Logship.
+func UpstreamResponse(uid string, endpoint string, statusCode int) {
+	upstreamResponses.WithLabelValues(uid, endpoint, strconv.Itoa(statusCode)).Inc()
+}
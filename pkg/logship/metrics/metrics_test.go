@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Run("should count auth tokens and the token cache", func(t *testing.T) {
+		AuthTokenFetched("uid-a", "jwt")
+		AuthTokenHit("uid-a", "jwt")
+		AuthTokenHit("uid-a", "jwt")
+		AuthTokenFailed("uid-a", "jwt")
+
+		assert.Equal(t, 1.0, testutil.ToFloat64(authTokens.WithLabelValues("uid-a", "jwt", "fetch")))
+		assert.Equal(t, 2.0, testutil.ToFloat64(authTokens.WithLabelValues("uid-a", "jwt", "hit")))
+		assert.Equal(t, 1.0, testutil.ToFloat64(authTokens.WithLabelValues("uid-a", "jwt", "failure")))
+		assert.Equal(t, 2.0, testutil.ToFloat64(cacheRequests.WithLabelValues("uid-a", "auth_token", "hit")))
+		assert.Equal(t, 2.0, testutil.ToFloat64(cacheRequests.WithLabelValues("uid-a", "auth_token", "miss")))
+	})
+
+	t.Run("should count upstream status codes per datasource", func(t *testing.T) {
+		UpstreamResponse("uid-a", "kusto", 200)
+		UpstreamResponse("uid-a", "kusto", 500)
+		UpstreamResponse("uid-b", "kusto", 500)
+
+		assert.Equal(t, 1.0, testutil.ToFloat64(upstreamResponses.WithLabelValues("uid-a", "kusto", "500")))
+		assert.Equal(t, 1.0, testutil.ToFloat64(upstreamResponses.WithLabelValues("uid-b", "kusto", "500")))
+	})
+
+	t.Run("should observe queries and responses", func(t *testing.T) {
+		ObserveQuery("uid-a", "raw", "table", 250*time.Millisecond)
+		ObserveResponseRows("uid-a", "table", 42)
+		ObserveResponseBytes("uid-a", 1024)
+
+		assert.Equal(t, 1, testutil.CollectAndCount(queryDuration))
+		assert.Equal(t, 1, testutil.CollectAndCount(responseRows))
+		assert.Equal(t, 1, testutil.CollectAndCount(responseBytes))
+	})
+}
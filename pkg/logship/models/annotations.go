@@ -0,0 +1,180 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// AnnotationOptions name the columns that annotations are built from. Columns
+// that are not named are detected from the column names and types.
+type AnnotationOptions struct {
+	TimeColumn    string   `json:"timeColumn"`
+	TimeEndColumn string   `json:"timeEndColumn"`
+	TitleColumn   string   `json:"titleColumn"`
+	TextColumn    string   `json:"textColumn"`
+	TagsColumns   []string `json:"tagsColumns"`
+}
+
+// column names, in order of preference, used to detect the annotation columns
+var (
+	annotationTimeColumns    = []string{"timestamp", "time", "starttime", "start"}
+	annotationTimeEndColumns = []string{"timeend", "endtime", "end_time", "end"}
+	annotationTitleColumns   = []string{"title", "name", "summary"}
+	annotationTextColumns    = []string{"text", "message", "description", "body", "details"}
+	annotationTagsColumns    = []string{"tags", "labels"}
+)
+
+// annotationColumns are the fields of a frame that annotations are built from.
+type annotationColumns struct {
+	time    *data.Field
+	timeEnd *data.Field
+	title   *data.Field
+	text    *data.Field
+	tags    []*data.Field
+}
+
+// AnnotationFrame converts a query result into a frame with the time, timeEnd,
+// title, text and tags fields that Grafana renders as annotations. Rows without
+// a time are dropped.
+func AnnotationFrame(frame *data.Frame, opts AnnotationOptions) (*data.Frame, error) {
+	cols, err := annotationFields(frame, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	times := []time.Time{}
+	timeEnds := []*time.Time{}
+	titles := []string{}
+	texts := []string{}
+	tags := []json.RawMessage{}
+	for i := 0; i < frame.Rows(); i++ {
+		t, ok := timeAt(cols.time, i)
+		if !ok {
+			continue
+		}
+		times = append(times, t)
+
+		if cols.timeEnd != nil {
+			var end *time.Time
+			if e, ok := timeAt(cols.timeEnd, i); ok {
+				end = &e
+			}
+			timeEnds = append(timeEnds, end)
+		}
+
+		titles = append(titles, stringAt(cols.title, i))
+		texts = append(texts, stringAt(cols.text, i))
+
+		rowTags := []string{}
+		for _, f := range cols.tags {
+			rowTags = append(rowTags, tagsAt(f, i)...)
+		}
+		b, err := jsoniter.Marshal(rowTags)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, b)
+	}
+
+	out := data.NewFrame(frame.Name, data.NewField("time", nil, times))
+	if cols.timeEnd != nil {
+		out.Fields = append(out.Fields, data.NewField("timeEnd", nil, timeEnds))
+	}
+	out.Fields = append(out.Fields,
+		data.NewField("title", nil, titles),
+		data.NewField("text", nil, texts),
+		data.NewField("tags", nil, tags),
+	)
+	out.Meta = frame.Meta
+	return out, nil
+}
+
+func annotationFields(frame *data.Frame, opts AnnotationOptions) (annotationColumns, error) {
+	cols := annotationColumns{}
+	finder := newColumnFinder(frame)
+
+	var err error
+	if cols.time, err = finder.find(opts.TimeColumn, annotationTimeColumns, isTimeField); err != nil {
+		return cols, err
+	}
+	if cols.time == nil {
+		cols.time = finder.first(isTimeField)
+	}
+	if cols.time == nil {
+		return cols, fmt.Errorf("annotations require a datetime column")
+	}
+
+	if cols.timeEnd, err = finder.find(opts.TimeEndColumn, annotationTimeEndColumns, isTimeField); err != nil {
+		return cols, err
+	}
+	if cols.title, err = finder.find(opts.TitleColumn, annotationTitleColumns, anyField); err != nil {
+		return cols, err
+	}
+	if cols.text, err = finder.find(opts.TextColumn, annotationTextColumns, anyField); err != nil {
+		return cols, err
+	}
+	if cols.text == nil {
+		cols.text = finder.first(func(f *data.Field) bool { return isStringField(f) && !isTagsName(f.Name) })
+	}
+
+	if len(opts.TagsColumns) > 0 {
+		for _, name := range opts.TagsColumns {
+			f, err := finder.find(name, nil, anyField)
+			if err != nil {
+				return cols, err
+			}
+			cols.tags = append(cols.tags, f)
+		}
+	} else if f, _ := finder.find("", annotationTagsColumns, anyField); f != nil {
+		cols.tags = []*data.Field{f}
+	}
+	return cols, nil
+}
+
+func isTagsName(name string) bool {
+	return contains(annotationTagsColumns, strings.ToLower(name))
+}
+
+// tagsAt returns the tags of a row: the elements of a JSON array, the values of a
+// JSON object as key:value, or the comma separated parts of any other value.
+func tagsAt(f *data.Field, i int) []string {
+	s := strings.TrimSpace(stringAt(f, i))
+	if s == "" {
+		return nil
+	}
+
+	var list []interface{}
+	if err := jsoniter.UnmarshalFromString(s, &list); err == nil {
+		tags := make([]string, 0, len(list))
+		for _, v := range list {
+			if v != nil {
+				tags = append(tags, fmt.Sprint(v))
+			}
+		}
+		return tags
+	}
+
+	var object map[string]interface{}
+	if err := jsoniter.UnmarshalFromString(s, &object); err == nil {
+		tags := make([]string, 0, len(object))
+		for k, v := range object {
+			tags = append(tags, fmt.Sprintf("%s:%v", k, v))
+		}
+		sort.Strings(tags)
+		return tags
+	}
+
+	tags := []string{}
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotationFrame(t *testing.T) {
+	t1 := time.Date(2023, 1, 1, 10, 0, 0, 0, time.UTC)
+	t2 := time.Date(2023, 1, 1, 11, 0, 0, 0, time.UTC)
+
+	events := func() *data.Frame {
+		return data.NewFrame("",
+			nullableField("Deployed", t1, nil, t2),
+			nullableField("Finished", t2, t2, nil),
+			nullableField("Service", "api", "web", "db"),
+			nullableField("Message", "v1.2", "v2", "v3"),
+			nullableField("Tags", `["prod","eu"]`, `[]`, `{"env":"dev"}`),
+			nullableField("Owner", "alice", nil, "bob, carol"),
+		)
+	}
+
+	tests := []struct {
+		name    string
+		opts    AnnotationOptions
+		errorIs assert.ErrorAssertionFunc
+		frame   *data.Frame
+	}{
+		{
+			name:    "detected columns",
+			errorIs: assert.NoError,
+			frame: data.NewFrame("",
+				data.NewField("time", nil, []time.Time{t1, t2}),
+				data.NewField("title", nil, []string{"", ""}),
+				data.NewField("text", nil, []string{"v1.2", "v3"}),
+				data.NewField("tags", nil, []json.RawMessage{json.RawMessage(`["prod","eu"]`), json.RawMessage(`["env:dev"]`)}),
+			),
+		},
+		{
+			name: "overridden columns",
+			opts: AnnotationOptions{
+				TimeColumn:    "Deployed",
+				TimeEndColumn: "Finished",
+				TitleColumn:   "Service",
+				TextColumn:    "Message",
+				TagsColumns:   []string{"Owner", "Tags"},
+			},
+			errorIs: assert.NoError,
+			frame: data.NewFrame("",
+				data.NewField("time", nil, []time.Time{t1, t2}),
+				data.NewField("timeEnd", nil, []*time.Time{&t2, nil}),
+				data.NewField("title", nil, []string{"api", "db"}),
+				data.NewField("text", nil, []string{"v1.2", "v3"}),
+				data.NewField("tags", nil, []json.RawMessage{json.RawMessage(`["alice","prod","eu"]`), json.RawMessage(`["bob","carol","env:dev"]`)}),
+			),
+		},
+		{
+			name:    "missing column",
+			opts:    AnnotationOptions{TitleColumn: "Missing"},
+			errorIs: assert.Error,
+		},
+		{
+			name:    "time column that is not a datetime",
+			opts:    AnnotationOptions{TimeColumn: "Service"},
+			errorIs: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := AnnotationFrame(events(), tt.opts)
+			tt.errorIs(t, err)
+			if tt.frame != nil {
+				require.Equal(t, tt.frame, frame)
+			}
+		})
+	}
+
+	t.Run("frames without a datetime column are rejected", func(t *testing.T) {
+		_, err := AnnotationFrame(data.NewFrame("", data.NewField("Message", nil, []string{"a"})), AnnotationOptions{})
+		require.Error(t, err)
+	})
+}
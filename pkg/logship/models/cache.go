@@ -2,7 +2,6 @@ package models
 
 import (
 	"fmt"
-	"regexp"
 	"strings"
 	"time"
 
@@ -89,30 +88,64 @@ func calculateCacheMaxAge(resolution time.Duration, tr *backend.TimeRange, since
 	return cacheMinAge
 }
 
-// binSizeRE finds the bin size of a query by looking at the summarize statement
-// e.g. summarize avg(Column), count(Column) by bin(TimeColumn, 1d)
-var binSizeRE = regexp.MustCompile(`by bin\([\w\$\(\)\.]+, ` +
-	`((?:\$__\w+)|(?:\d{1,10}(?:h|m|ms)?))\)`) // in format e.g. $__timeInterval, 1d, 1h, 1s, 1m or 1ms
+// binSizes finds the time bin sizes of a query by parsing its summarize and
+// make-series operators, in the order they appear. For example:
+//
+//	summarize avg(Column) by Host, bin(TimeColumn, 1d)  -> 1d
+//	summarize count() by bin_at(TimeColumn, 1h, now())  -> 1h
+//	make-series avg(Column) on TimeColumn step 5m       -> 5m
+func binSizes(query string) []string {
+	sizes := []string{}
+	for _, stage := range kqlStages(query) {
+		op, rest := kqlOperator(stage)
+		switch op {
+		case "summarize":
+			by := kqlKeywordIndexes(rest, "by")
+			if len(by) == 0 {
+				continue
+			}
+			for _, call := range kqlCalls(rest[by[len(by)-1]:], "bin", "bin_at", "floor") {
+				if len(call.Args) >= 2 {
+					sizes = append(sizes, call.Args[1])
+				}
+			}
+		case "make-series", "make_series":
+			step := kqlKeywordIndexes(rest, "step")
+			if len(step) == 0 {
+				continue
+			}
+			size := rest[step[len(step)-1]+len("step"):]
+			if by := kqlKeywordIndexes(size, "by"); len(by) > 0 {
+				size = size[:by[0]]
+			}
+			sizes = append(sizes, strings.TrimSpace(size))
+		}
+	}
+	return sizes
+}
 
+// detectResolution returns the time resolution of the query, which is the last
+// time bin size of the query if it is larger than the interval, otherwise the interval.
 func detectResolution(query string, interval time.Duration) time.Duration {
-	match := binSizeRE.FindStringSubmatch(query)
-	if len(match) != 2 {
-		return intervalOrDefault(interval)
-	}
+	sizes := binSizes(query)
+	for i := len(sizes) - 1; i >= 0; i-- {
+		if macroRE.MatchString(sizes[i]) {
+			return intervalOrDefault(interval)
+		}
 
-	if macroRE.MatchString(match[1]) {
-		return intervalOrDefault(interval)
-	}
+		d, err := ParseTimespan(sizes[i])
+		if err != nil || d <= 0 {
+			// numeric bins, e.g. bin(Duration, 100), are not time bins
+			continue
+		}
 
-	d, err := time.ParseDuration(match[1])
-	if err != nil {
-		return intervalOrDefault(interval)
+		if d <= interval {
+			return intervalOrDefault(interval)
+		}
+		return d
 	}
 
-	if d <= interval {
-		return intervalOrDefault(interval)
-	}
-	return d
+	return intervalOrDefault(interval)
 }
 
 func intervalOrDefault(interval time.Duration) time.Duration {
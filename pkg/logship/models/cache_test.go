@@ -212,3 +212,97 @@ func TestNewCacheSettings(t *testing.T) {
 		})
 	}
 }
+
+func TestDetectResolution(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		interval time.Duration
+		want     time.Duration
+	}{
+		{
+			name:     "should detect day bins",
+			query:    `T | summarize count() by bin(TimeColumn, 1d)`,
+			interval: time.Minute,
+			want:     24 * time.Hour,
+		},
+		{
+			name:     "should detect second bins",
+			query:    `T | summarize count() by bin(TimeColumn, 30s)`,
+			interval: time.Second,
+			want:     30 * time.Second,
+		},
+		{
+			name:     "should detect bins with spaces and other group by columns",
+			query:    `T | summarize avg(Column) by Host, bin( TimeColumn ,  2h ), State`,
+			interval: time.Minute,
+			want:     2 * time.Hour,
+		},
+		{
+			name:     "should detect bin_at",
+			query:    `T | summarize count() by bin_at(TimeColumn, 1h, datetime(2020-01-01))`,
+			interval: time.Minute,
+			want:     time.Hour,
+		},
+		{
+			name:     "should detect timespan functions",
+			query:    `T | summarize count() by bin(TimeColumn, time(0.06:00:00))`,
+			interval: time.Minute,
+			want:     6 * time.Hour,
+		},
+		{
+			name:     "should detect make-series step",
+			query:    `T | make-series avg(Column) default=0 on TimeColumn from ago(7d) to now() step 15m by Host`,
+			interval: time.Minute,
+			want:     15 * time.Minute,
+		},
+		{
+			name:     "should skip numeric bins",
+			query:    `T | summarize count() by bin(TimeColumn, 5m), bin(Duration, 100)`,
+			interval: time.Minute,
+			want:     5 * time.Minute,
+		},
+		{
+			name:     "should use the last summarize",
+			query:    `T | summarize count() by bin(TimeColumn, 1m) | summarize max(count_) by bin(TimeColumn, 1h)`,
+			interval: time.Second,
+			want:     time.Hour,
+		},
+		{
+			name:     "should ignore bins in strings and comments",
+			query:    "T // summarize count() by bin(TimeColumn, 1d)\n| where Message == 'by bin(TimeColumn, 1d)'",
+			interval: time.Minute,
+			want:     time.Minute,
+		},
+		{
+			name:     "should ignore bins outside the by clause",
+			query:    `T | summarize count(), take_any(bin(TimeColumn, 1d)) by Host`,
+			interval: time.Minute,
+			want:     time.Minute,
+		},
+		{
+			name:     "should use the interval for macros",
+			query:    `T | summarize count() by bin(TimeColumn, $__timeInterval)`,
+			interval: time.Minute,
+			want:     time.Minute,
+		},
+		{
+			name:     "should use the interval when the bin is smaller",
+			query:    `T | summarize count() by bin(TimeColumn, 1ms)`,
+			interval: time.Minute,
+			want:     time.Minute,
+		},
+		{
+			name:     "should default to one second",
+			query:    `T | take 10`,
+			interval: 0,
+			want:     time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, detectResolution(tt.query, tt.interval))
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	jsoniter "github.com/json-iterator/go"
+)
+
+//  Query chaining:
+//   - $__result(A, Column) -> dynamic(["value1", "value2"]) built from the distinct values
+//     of Column in the result of the query with RefID A.
+
+// resultRE is a regular expression to match $__result(RefID, Column) macros.
+var resultRE = regexp.MustCompile(`\$__result\(\s*([\w-]+)\s*,\s*([^)]*?)\s*\)`)
+
+// ResultDependencies returns the distinct RefIDs referenced by $__result macros in the query.
+func ResultDependencies(query string) []string {
+	refIDs := []string{}
+	seen := map[string]bool{}
+	for _, match := range resultRE.FindAllStringSubmatch(query, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			refIDs = append(refIDs, match[1])
+		}
+	}
+	return refIDs
+}
+
+// SortByDependencies orders the RefIDs so that every query runs after the queries
+// it depends on, keeping the original order otherwise. Queries that reference an
+// unknown RefID or are part of a dependency cycle are returned as errors instead.
+func SortByDependencies(refIDs []string, dependencies map[string][]string) ([]string, map[string]error) {
+	errs := map[string]error{}
+	known := map[string]bool{}
+	for _, refID := range refIDs {
+		known[refID] = true
+	}
+	for _, refID := range refIDs {
+		for _, dep := range dependencies[refID] {
+			if !known[dep] {
+				errs[refID] = fmt.Errorf("$__result references unknown query %q", dep)
+			}
+		}
+	}
+
+	// failed queries count as done so that their dependents report the failure
+	order := []string{}
+	done := map[string]bool{}
+	for refID := range errs {
+		done[refID] = true
+	}
+	for progress := true; progress; {
+		progress = false
+		for _, refID := range refIDs {
+			if done[refID] || !dependenciesDone(dependencies[refID], done) {
+				continue
+			}
+			done[refID] = true
+			progress = true
+			order = append(order, refID)
+		}
+	}
+
+	for _, refID := range refIDs {
+		if done[refID] {
+			continue
+		}
+		if cycle := findCycle(refID, dependencies, done, []string{refID}); cycle != nil {
+			errs[refID] = fmt.Errorf("circular $__result dependency: %s", strings.Join(cycle, " -> "))
+		} else {
+			errs[refID] = fmt.Errorf("query depends on a query with a circular $__result dependency")
+		}
+	}
+
+	return order, errs
+}
+
+func dependenciesDone(dependencies []string, done map[string]bool) bool {
+	for _, dep := range dependencies {
+		if !done[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+// findCycle returns the path from the last RefID of path back to its first RefID, if any.
+func findCycle(start string, dependencies map[string][]string, done map[string]bool, path []string) []string {
+	for _, dep := range dependencies[path[len(path)-1]] {
+		if dep == start {
+			return append(path, dep)
+		}
+		if done[dep] || contains(path, dep) {
+			continue
+		}
+		if cycle := findCycle(start, dependencies, done, append(path, dep)); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// InterpolateEmptyResults replaces $__result macros with empty dynamic lists, for
+// when the referenced queries are not available, e.g. when validating a query.
+func InterpolateEmptyResults(query string) string {
+	return resultRE.ReplaceAllString(query, "dynamic([])")
+}
+
+// InterpolateResults replaces $__result macros with a dynamic list of the distinct,
+// non-null values of the column in the referenced query's response.
+func InterpolateResults(query string, results backend.Responses) (string, error) {
+	errorStrings := []string{}
+	interpolated := resultRE.ReplaceAllStringFunc(query, func(match string) string {
+		groups := resultRE.FindStringSubmatch(match)
+		list, err := resultList(groups[1], unquoteIdentifier(groups[2]), results)
+		if err != nil {
+			errorStrings = append(errorStrings, err.Error())
+			return ""
+		}
+		return list
+	})
+	if len(errorStrings) > 0 {
+		return "", fmt.Errorf("failed to interpolate query, errors: %v", strings.Join(errorStrings, "\n"))
+	}
+	return interpolated, nil
+}
+
+func resultList(refID string, column string, results backend.Responses) (string, error) {
+	res, ok := results[refID]
+	if !ok {
+		return "", fmt.Errorf("$__result(%s, %s): query %s has no result", refID, column, refID)
+	}
+	if res.Error != nil {
+		return "", fmt.Errorf("$__result(%s, %s): query %s failed: %v", refID, column, refID, res.Error)
+	}
+
+	values := []string{}
+	seen := map[string]bool{}
+	found := false
+	for _, frame := range res.Frames {
+		field, idx := frame.FieldByName(column)
+		if idx < 0 {
+			continue
+		}
+		found = true
+		for i := 0; i < field.Len(); i++ {
+			v, ok := field.ConcreteAt(i)
+			if !ok {
+				continue
+			}
+			literal, err := resultLiteral(v)
+			if err != nil {
+				return "", fmt.Errorf("$__result(%s, %s): %w", refID, column, err)
+			}
+			if !seen[literal] {
+				seen[literal] = true
+				values = append(values, literal)
+			}
+		}
+	}
+	if !found {
+		return "", fmt.Errorf("$__result(%s, %s): column %s not found in the result of query %s", refID, column, column, refID)
+	}
+
+	return fmt.Sprintf("dynamic([%s])", strings.Join(values, ", ")), nil
+}
+
+// unquoteIdentifier removes KQL identifier quoting, e.g. ['Column Name'] or "Column Name".
+func unquoteIdentifier(s string) string {
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		s = s[1 : len(s)-1]
+	}
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		s = s[1 : len(s)-1]
+	}
+	return s
+}
+
+// resultLiteral formats a value of a result as an element of a dynamic array.
+// Strings are quoted as KQL strings, since JSON would escape HTML characters.
+func resultLiteral(v interface{}) (string, error) {
+	if s, ok := v.(string); ok {
+		return QuoteString(s), nil
+	}
+	b, err := jsoniter.Marshal(v)
+	return string(b), err
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xorcare/pointer"
+)
+
+func TestResultDependencies(t *testing.T) {
+	query := `T | where Service in ($__result(A, Service)) and Host in ($__result( B , ['Host Name'] )) | where Region in ($__result(A, Region))`
+	assert.Equal(t, []string{"A", "B"}, ResultDependencies(query))
+	assert.Empty(t, ResultDependencies("T | take 10"))
+}
+
+func TestSortByDependencies(t *testing.T) {
+	tests := []struct {
+		name         string
+		refIDs       []string
+		dependencies map[string][]string
+		order        []string
+		errs         map[string]string
+	}{
+		{
+			name:   "should keep order without dependencies",
+			refIDs: []string{"A", "B", "C"},
+			order:  []string{"A", "B", "C"},
+			errs:   map[string]string{},
+		},
+		{
+			name:         "should run dependencies first",
+			refIDs:       []string{"A", "B", "C"},
+			dependencies: map[string][]string{"A": {"C"}, "C": {"B"}},
+			order:        []string{"B", "C", "A"},
+			errs:         map[string]string{},
+		},
+		{
+			name:         "should fail unknown references",
+			refIDs:       []string{"A", "B"},
+			dependencies: map[string][]string{"A": {"Z"}, "B": {"A"}},
+			order:        []string{"B"},
+			errs:         map[string]string{"A": `$__result references unknown query "Z"`},
+		},
+		{
+			name:         "should detect cycles",
+			refIDs:       []string{"A", "B", "C", "D"},
+			dependencies: map[string][]string{"A": {"B"}, "B": {"A"}, "C": {"A"}},
+			order:        []string{"D"},
+			errs: map[string]string{
+				"A": "circular $__result dependency: A -> B -> A",
+				"B": "circular $__result dependency: B -> A -> B",
+				"C": "query depends on a query with a circular $__result dependency",
+			},
+		},
+		{
+			name:         "should detect self references",
+			refIDs:       []string{"A"},
+			dependencies: map[string][]string{"A": {"A"}},
+			order:        []string{},
+			errs:         map[string]string{"A": "circular $__result dependency: A -> A"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, errs := SortByDependencies(tt.refIDs, tt.dependencies)
+			assert.Equal(t, tt.order, order)
+			msgs := map[string]string{}
+			for refID, err := range errs {
+				msgs[refID] = err.Error()
+			}
+			assert.Equal(t, tt.errs, msgs)
+		})
+	}
+}
+
+func TestInterpolateResults(t *testing.T) {
+	results := backend.Responses{
+		"A": backend.DataResponse{Frames: data.Frames{data.NewFrame("",
+			data.NewField("Service", nil, []*string{pointer.String("checkout"), pointer.String("cart"), nil, pointer.String("checkout")}),
+			data.NewField("Errors", nil, []*int64{pointer.Int64(10), pointer.Int64(5), pointer.Int64(1), pointer.Int64(10)}),
+			data.NewField("Host Name", nil, []string{`a"b`, "c", "<d>", "e&f"}),
+		)}},
+		"B": backend.DataResponse{Error: fmt.Errorf("HTTP 500")},
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		errorIs   require.ErrorAssertionFunc
+		returnVal string
+	}{
+		{
+			name:      "should expand distinct strings",
+			query:     "T | where Service in ($__result(A, Service))",
+			errorIs:   require.NoError,
+			returnVal: `T | where Service in (dynamic(["checkout", "cart"]))`,
+		},
+		{
+			name:      "should expand numbers",
+			query:     "T | where Errors in ($__result(A, Errors))",
+			errorIs:   require.NoError,
+			returnVal: `T | where Errors in (dynamic([10, 5, 1]))`,
+		},
+		{
+			name:      "should expand quoted columns and escape values",
+			query:     "T | where Host in ($__result(A, ['Host Name']))",
+			errorIs:   require.NoError,
+			returnVal: `T | where Host in (dynamic(["a\"b", "c", "<d>", "e&f"]))`,
+		},
+		{
+			name:    "should fail for unknown columns",
+			query:   "T | where Host in ($__result(A, Missing))",
+			errorIs: require.Error,
+		},
+		{
+			name:    "should fail for failed queries",
+			query:   "T | where Host in ($__result(B, Host))",
+			errorIs: require.Error,
+		},
+		{
+			name:    "should fail for missing results",
+			query:   "T | where Host in ($__result(C, Host))",
+			errorIs: require.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			val, err := InterpolateResults(tt.query, results)
+			tt.errorIs(t, err)
+			assert.Equal(t, tt.returnVal, val)
+		})
+	}
+}
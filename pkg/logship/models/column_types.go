@@ -0,0 +1,82 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// ColumnTypeMappings convert the columns of a Logship type like the columns of
+// another type, e.g. {"UInt128": "Int128", "Date": "DateTime"}. They are set in
+// the datasource settings and take precedence over the converters of converterMap.
+type ColumnTypeMappings map[string]string
+
+// valid returns the mappings of a type to a type with a converter, along with
+// an error for every other mapping so that they can be reported and ignored.
+func (m ColumnTypeMappings) valid() (ColumnTypeMappings, []error) {
+	types := make([]string, 0, len(m))
+	for from := range m {
+		types = append(types, from)
+	}
+	sort.Strings(types)
+
+	var errs []error
+	valid := make(ColumnTypeMappings, len(m))
+	for _, from := range types {
+		to := m[from]
+		if strings.TrimSpace(from) == "" {
+			errs = append(errs, fmt.Errorf("column type mapping to %q has no column type", to))
+			continue
+		}
+		if _, ok := converterMap[to]; !ok {
+			errs = append(errs, fmt.Errorf("column type %q is mapped to the unsupported type %q, supported types are %s", from, to, strings.Join(ColumnTypes(), ", ")))
+			continue
+		}
+		valid[from] = to
+	}
+	return valid, errs
+}
+
+// resolve returns the type whose converter converts columns of the type, and
+// false when there is none.
+func (m ColumnTypeMappings) resolve(typ string) (string, bool) {
+	if to, ok := m[typ]; ok {
+		typ = to
+	}
+	_, ok := converterMap[typ]
+	return typ, ok
+}
+
+// ColumnTypes returns the sorted column types that have a converter.
+func ColumnTypes() []string {
+	types := make([]string, 0, len(converterMap))
+	for typ := range converterMap {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// fallbackConverter converts the values of columns of unsupported types: strings
+// are kept and any other value is formatted as JSON.
+var fallbackConverter = data.FieldConverter{
+	OutputFieldType: data.FieldTypeNullableString,
+	Converter: func(v interface{}) (interface{}, error) {
+		var as *string
+		if v == nil {
+			return as, nil
+		}
+		if s, ok := v.(string); ok {
+			return &s, nil
+		}
+		b, err := jsoniter.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal value into JSON string '%v': %v", v, err)
+		}
+		s := string(b)
+		return &s, nil
+	},
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+	"github.com/xorcare/pointer"
+)
+
+func TestColumnTypeMappingsValid(t *testing.T) {
+	tests := []struct {
+		name     string
+		mappings ColumnTypeMappings
+		valid    ColumnTypeMappings
+		invalid  int
+	}{
+		{name: "none", valid: ColumnTypeMappings{}},
+		{name: "supported types", mappings: ColumnTypeMappings{"UInt128": "Int128", "Int64": "String"}, valid: ColumnTypeMappings{"UInt128": "Int128", "Int64": "String"}},
+		{name: "unsupported type", mappings: ColumnTypeMappings{"UInt128": "UInt256", "Date": "DateTime"}, valid: ColumnTypeMappings{"Date": "DateTime"}, invalid: 1},
+		{name: "empty type", mappings: ColumnTypeMappings{" ": "String", "Date": "Int64"}, valid: ColumnTypeMappings{"Date": "Int64"}, invalid: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, invalid := tt.mappings.valid()
+			require.Equal(t, tt.valid, valid)
+			require.Len(t, invalid, tt.invalid)
+		})
+	}
+}
+
+func TestLoad_IgnoresInvalidTypeMappings(t *testing.T) {
+	settings := &DatasourceSettings{}
+	err := settings.Load(backend.DataSourceInstanceSettings{
+		JSONData: []byte(`{"typeMappings": {"UInt128": "UInt256", "Date": "DateTime"}}`),
+	})
+	require.NoError(t, err)
+	require.Equal(t, ColumnTypeMappings{"Date": "DateTime"}, settings.TypeMappings)
+}
+
+func TestUnsupportedColumnTypes(t *testing.T) {
+	tr, err := TableFromJSON(strings.NewReader(`{
+		"Columns": [{"Name": "Name", "Type": "String"}, {"Name": "Big", "Type": "UInt128"}, {"Name": "Shape", "Type": "Geography"}],
+		"Results": [
+			{"Name": "a", "Big": 340282366920938463463374607431768211455, "Shape": {"type": "Point"}},
+			{"Name": "b", "Big": null, "Shape": "POINT (1 2)"}
+		]
+	}`))
+	require.NoError(t, err)
+
+	t.Run("fall back to strings", func(t *testing.T) {
+		frames, err := tr.ToDataFrames("", FrameOptions{})
+		require.NoError(t, err)
+		require.Equal(t, []*data.Field{
+			withLogshipType("String", data.NewField("Name", nil, []*string{pointer.String("a"), pointer.String("b")})),
+			withLogshipType("UInt128", data.NewField("Big", nil, []*string{pointer.String("340282366920938463463374607431768211455"), nil})),
+			withLogshipType("Geography", data.NewField("Shape", nil, []*string{pointer.String(`{"type":"Point"}`), pointer.String("POINT (1 2)")})),
+		}, frames[0].Fields)
+		require.Len(t, frames[0].Meta.Notices, 2)
+		require.Equal(t, data.NoticeSeverityWarning, frames[0].Meta.Notices[0].Severity)
+		require.Contains(t, frames[0].Meta.Notices[0].Text, `"Big" has the unsupported type "UInt128"`)
+		require.Equal(t, []string{"String", "UInt128", "Geography"}, frames[0].Meta.Custom.(LogshipFrameMD).ColumnTypes)
+	})
+
+	t.Run("mapped types", func(t *testing.T) {
+		frames, err := tr.ToDataFrames("", FrameOptions{
+			TypeMappings: ColumnTypeMappings{"UInt128": "Float64", "Geography": "Dynamic", "String": "Dynamic"},
+		})
+		require.NoError(t, err)
+		require.Empty(t, frames[0].Meta.Notices)
+		require.Equal(t, []*data.Field{
+			withLogshipType("String", data.NewField("Name", nil, []string{`"a"`, `"b"`})),
+			withLogshipType("UInt128", data.NewField("Big", nil, []*float64{pointer.Float64(3.402823669209385e38), nil})),
+			withLogshipType("Geography", data.NewField("Shape", nil, []string{`{"type":"Point"}`, `"POINT (1 2)"`})),
+		}, frames[0].Fields)
+	})
+}
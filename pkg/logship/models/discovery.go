@@ -0,0 +1,293 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+const (
+	defaultPageLimit   = 100
+	maxPageLimit       = 1000
+	defaultValuesLimit = 20
+	maxValuesLimit     = 1000
+)
+
+// Page is the window of a list returned by a discovery resource request.
+type Page struct {
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+	Total  int `json:"total"`
+}
+
+// TableSummary describes a table without its columns.
+type TableSummary struct {
+	Name    string `json:"name"`
+	Columns int    `json:"columns"`
+}
+
+// TableList is the response of the /tables resource.
+type TableList struct {
+	Page
+	Tables []TableSummary `json:"tables"`
+}
+
+// ColumnList is the response of the /tables/{name}/columns resource.
+type ColumnList struct {
+	Page
+	Table   string         `json:"table"`
+	Columns []ColumnSchema `json:"columns"`
+}
+
+// ColumnValue is a distinct value of a column and the number of rows it appears in.
+type ColumnValue struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// ColumnValueList is the response of the /tables/{name}/columns/{col}/values resource.
+type ColumnValueList struct {
+	Table  string        `json:"table"`
+	Column string        `json:"column"`
+	Values []ColumnValue `json:"values"`
+}
+
+// DiscoveryParams are the query string parameters of the discovery resources.
+type DiscoveryParams struct {
+	Search string
+	Offset int
+	Limit  int
+	// TimeRange is only used for column values, it defaults to the last hour.
+	TimeRange backend.TimeRange
+}
+
+// ParseDiscoveryParams reads the search, offset, limit, from and to (epoch milliseconds)
+// parameters. defaultLimit and maxLimit bound the limit.
+func ParseDiscoveryParams(values url.Values, defaultLimit int, maxLimit int) (DiscoveryParams, error) {
+	p := DiscoveryParams{Search: values.Get("search"), Limit: defaultLimit}
+
+	var err error
+	if p.Offset, err = intParam(values, "offset", 0); err != nil {
+		return p, err
+	}
+	if p.Limit, err = intParam(values, "limit", defaultLimit); err != nil {
+		return p, err
+	}
+	if p.Limit == 0 || p.Limit > maxLimit {
+		return p, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+	}
+
+	from, err := intParam(values, "from", 0)
+	if err != nil {
+		return p, err
+	}
+	to, err := intParam(values, "to", 0)
+	if err != nil {
+		return p, err
+	}
+	p.TimeRange = TimeRangeOrDefault(int64(from), int64(to))
+	return p, nil
+}
+
+// ParsePageParams reads the parameters of the paginated table and column lists.
+func ParsePageParams(values url.Values) (DiscoveryParams, error) {
+	return ParseDiscoveryParams(values, defaultPageLimit, maxPageLimit)
+}
+
+// ParseValuesParams reads the parameters of the column values list.
+func ParseValuesParams(values url.Values) (DiscoveryParams, error) {
+	return ParseDiscoveryParams(values, defaultValuesLimit, maxValuesLimit)
+}
+
+func intParam(values url.Values, name string, def int) (int, error) {
+	s := values.Get(name)
+	if s == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, s)
+	}
+	return v, nil
+}
+
+// TimeRangeOrDefault returns the time range between the epoch milliseconds, where to
+// defaults to now and from to an hour before to.
+func TimeRangeOrDefault(from int64, to int64) backend.TimeRange {
+	tr := backend.TimeRange{To: time.Now()}
+	if to > 0 {
+		tr.To = time.UnixMilli(to)
+	}
+	tr.From = tr.To.Add(-time.Hour)
+	if from > 0 {
+		tr.From = time.UnixMilli(from)
+	}
+	return tr
+}
+
+// ListTables returns the page of tables whose name contains the search text.
+func ListTables(tables []TableSchema, p DiscoveryParams) TableList {
+	matches := []TableSummary{}
+	for _, t := range tables {
+		if containsFold(t.Name, p.Search) {
+			matches = append(matches, TableSummary{Name: t.Name, Columns: len(t.Columns)})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Name < matches[j].Name })
+
+	start, end := pageBounds(len(matches), p)
+	return TableList{
+		Page:   Page{Offset: p.Offset, Limit: p.Limit, Total: len(matches)},
+		Tables: matches[start:end],
+	}
+}
+
+// ListColumns returns the page of columns of the table whose name contains the search text.
+func ListColumns(table TableSchema, p DiscoveryParams) ColumnList {
+	matches := []ColumnSchema{}
+	for _, c := range table.Columns {
+		if containsFold(c.Name, p.Search) {
+			matches = append(matches, c)
+		}
+	}
+
+	start, end := pageBounds(len(matches), p)
+	return ColumnList{
+		Page:    Page{Offset: p.Offset, Limit: p.Limit, Total: len(matches)},
+		Table:   table.Name,
+		Columns: matches[start:end],
+	}
+}
+
+func pageBounds(total int, p DiscoveryParams) (int, int) {
+	start := p.Offset
+	if start > total {
+		start = total
+	}
+	end := start + p.Limit
+	if end > total {
+		end = total
+	}
+	return start, end
+}
+
+func containsFold(s string, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// FindTable returns the table with the given name.
+func FindTable(tables []TableSchema, name string) (TableSchema, bool) {
+	for _, t := range tables {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return TableSchema{}, false
+}
+
+// FindColumn returns the column of the table with the given name.
+func (t TableSchema) FindColumn(name string) (ColumnSchema, bool) {
+	for _, c := range t.Columns {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return ColumnSchema{}, false
+}
+
+// TimeColumn returns the column used to filter the table on the time range: a
+// datetime column named timestamp if there is one, otherwise the first datetime column.
+func (t TableSchema) TimeColumn() (string, bool) {
+	found := ""
+	for _, c := range t.Columns {
+		if !strings.EqualFold(c.Type, "datetime") {
+			continue
+		}
+		if strings.EqualFold(c.Name, "timestamp") {
+			return c.Name, true
+		}
+		if found == "" {
+			found = c.Name
+		}
+	}
+	return found, found != ""
+}
+
+// ColumnValuesQuery returns the query for the top distinct values of a column within
+// the time range, optionally only those containing the search text.
+func ColumnValuesQuery(table TableSchema, column string, p DiscoveryParams) string {
+	var b strings.Builder
+	b.WriteString(QuoteIdentifier(table.Name))
+	if timeColumn, ok := table.TimeColumn(); ok {
+		tc := QuoteIdentifier(timeColumn)
+		fmt.Fprintf(&b, "\n| where %s >= datetime(%s) and %s <= datetime(%s)", tc,
+			p.TimeRange.From.UTC().Format(time.RFC3339Nano), tc, p.TimeRange.To.UTC().Format(time.RFC3339Nano))
+	}
+
+	value := fmt.Sprintf("tostring(%s)", QuoteIdentifier(column))
+	fmt.Fprintf(&b, "\n| where isnotempty(%s)", value)
+	if p.Search != "" {
+		fmt.Fprintf(&b, "\n| where %s contains %s", value, QuoteString(p.Search))
+	}
+	fmt.Fprintf(&b, "\n| summarize Count = count() by Value = %s", value)
+	fmt.Fprintf(&b, "\n| top %d by Count desc, Value asc", p.Limit)
+	return b.String()
+}
+
+// ColumnValuesFromResults reads the rows of a ColumnValuesQuery.
+func ColumnValuesFromResults(results []map[string]interface{}) []ColumnValue {
+	values := make([]ColumnValue, 0, len(results))
+	for _, row := range results {
+		value, _ := row["Value"].(string)
+		values = append(values, ColumnValue{Value: value, Count: toInt64(row["Count"])})
+	}
+	return values
+}
+
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	case interface{ Int64() (int64, error) }:
+		i, _ := n.Int64()
+		return i
+	}
+	return 0
+}
+
+// QuoteIdentifier quotes a table or column name for use in a query, e.g. ['Column Name'].
+func QuoteIdentifier(name string) string {
+	return "['" + escapeString(name, '\'') + "']"
+}
+
+// QuoteString returns a double quoted string literal for use in a query.
+func QuoteString(s string) string {
+	return `"` + escapeString(s, '"') + `"`
+}
+
+func escapeString(s string, quote byte) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\', quote:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
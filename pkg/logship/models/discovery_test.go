@@ -0,0 +1,151 @@
+package models
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDiscoveryParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		errorIs assert.ErrorAssertionFunc
+		params  DiscoveryParams
+	}{
+		{
+			name:    "defaults",
+			query:   "",
+			errorIs: assert.NoError,
+			params:  DiscoveryParams{Limit: defaultPageLimit},
+		},
+		{
+			name:    "search and page",
+			query:   "search=log&offset=20&limit=10",
+			errorIs: assert.NoError,
+			params:  DiscoveryParams{Search: "log", Offset: 20, Limit: 10},
+		},
+		{
+			name:    "limit above maximum",
+			query:   "limit=5000",
+			errorIs: assert.Error,
+		},
+		{
+			name:    "zero limit",
+			query:   "limit=0",
+			errorIs: assert.Error,
+		},
+		{
+			name:    "negative offset",
+			query:   "offset=-1",
+			errorIs: assert.Error,
+		},
+		{
+			name:    "invalid from",
+			query:   "from=yesterday",
+			errorIs: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+
+			params, err := ParsePageParams(values)
+			tt.errorIs(t, err)
+			if err == nil {
+				require.Equal(t, tt.params.Search, params.Search)
+				require.Equal(t, tt.params.Offset, params.Offset)
+				require.Equal(t, tt.params.Limit, params.Limit)
+				require.Equal(t, time.Hour, params.TimeRange.To.Sub(params.TimeRange.From))
+			}
+		})
+	}
+}
+
+func TestListTables(t *testing.T) {
+	tables := []TableSchema{
+		{Name: "Logs", Columns: []ColumnSchema{{Name: "a"}, {Name: "b"}}},
+		{Name: "Metrics", Columns: []ColumnSchema{{Name: "a"}}},
+		{Name: "AuditLogs", Columns: []ColumnSchema{}},
+	}
+
+	list := ListTables(tables, DiscoveryParams{Search: "logs", Limit: 1})
+	require.Equal(t, Page{Offset: 0, Limit: 1, Total: 2}, list.Page)
+	require.Equal(t, []TableSummary{{Name: "AuditLogs", Columns: 0}}, list.Tables)
+
+	list = ListTables(tables, DiscoveryParams{Search: "logs", Offset: 1, Limit: 1})
+	require.Equal(t, []TableSummary{{Name: "Logs", Columns: 2}}, list.Tables)
+
+	list = ListTables(tables, DiscoveryParams{Offset: 10, Limit: 1})
+	require.Equal(t, 3, list.Total)
+	require.Empty(t, list.Tables)
+
+	b, err := json.Marshal(list)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"offset":10,"limit":1,"total":3,"tables":[]}`, string(b))
+}
+
+func TestListColumns(t *testing.T) {
+	table := TableSchema{Name: "Logs", Columns: []ColumnSchema{
+		{Name: "timestamp", Type: "DateTime"},
+		{Name: "Host", Type: "string"},
+		{Name: "HostIp", Type: "string"},
+	}}
+
+	list := ListColumns(table, DiscoveryParams{Search: "host", Limit: 10})
+	require.Equal(t, "Logs", list.Table)
+	require.Equal(t, 2, list.Total)
+	require.Equal(t, []ColumnSchema{{Name: "Host", Type: "string"}, {Name: "HostIp", Type: "string"}}, list.Columns)
+}
+
+func TestColumnValuesQuery(t *testing.T) {
+	tr := backend.TimeRange{
+		From: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2023, 1, 1, 1, 0, 0, 0, time.UTC),
+	}
+
+	t.Run("filters on the time column and search text", func(t *testing.T) {
+		table := TableSchema{Name: "My Logs", Columns: []ColumnSchema{
+			{Name: "created", Type: "DateTime"},
+			{Name: "timestamp", Type: "DateTime"},
+			{Name: "Host", Type: "string"},
+		}}
+		query := ColumnValuesQuery(table, "Host", DiscoveryParams{Search: `web "1"`, Limit: 5, TimeRange: tr})
+		require.Equal(t, `['My Logs']
+| where ['timestamp'] >= datetime(2023-01-01T00:00:00Z) and ['timestamp'] <= datetime(2023-01-01T01:00:00Z)
+| where isnotempty(tostring(['Host']))
+| where tostring(['Host']) contains "web \"1\""
+| summarize Count = count() by Value = tostring(['Host'])
+| top 5 by Count desc, Value asc`, query)
+	})
+
+	t.Run("tables without a time column are not filtered on time", func(t *testing.T) {
+		table := TableSchema{Name: "Hosts", Columns: []ColumnSchema{{Name: "Name", Type: "string"}}}
+		query := ColumnValuesQuery(table, "Name", DiscoveryParams{Limit: 5, TimeRange: tr})
+		require.Equal(t, `['Hosts']
+| where isnotempty(tostring(['Name']))
+| summarize Count = count() by Value = tostring(['Name'])
+| top 5 by Count desc, Value asc`, query)
+	})
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	require.Equal(t, `['Column Name']`, QuoteIdentifier("Column Name"))
+	require.Equal(t, `['it\'s']`, QuoteIdentifier("it's"))
+	require.Equal(t, `"a\\b\n"`, QuoteString("a\\b\n"))
+}
+
+func TestColumnValuesFromResults(t *testing.T) {
+	results := []map[string]interface{}{
+		{"Value": "web-1", "Count": json.Number("42")},
+		{"Value": "web-2", "Count": float64(7)},
+	}
+	require.Equal(t, []ColumnValue{{Value: "web-1", Count: 42}, {Value: "web-2", Count: 7}}, ColumnValuesFromResults(results))
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// SourcedError is an error tagged with where it originated from and the status it maps to,
+// so that Grafana can tell Logship failures apart from failures of the plugin itself.
+type SourcedError struct {
+	Source backend.ErrorSource
+	Status backend.Status
+	Err    error
+}
+
+func (e *SourcedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SourcedError) Unwrap() error {
+	return e.Err
+}
+
+// PluginError tags an error as caused by the plugin or the query it was given.
+func PluginError(status backend.Status, err error) error {
+	return &SourcedError{Source: backend.ErrorSourcePlugin, Status: status, Err: err}
+}
+
+// DownstreamError tags an error as caused by Logship, its auth provider or the network in between.
+func DownstreamError(status backend.Status, err error) error {
+	return &SourcedError{Source: backend.ErrorSourceDownstream, Status: status, Err: err}
+}
+
+// TransportError tags an error returned while sending a request to Logship.
+func TransportError(err error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return DownstreamError(backend.StatusTimeout, err)
+	}
+	return DownstreamError(backend.StatusBadGateway, err)
+}
+
+// ClassifyError returns the status and source of an error. Errors that were not
+// tagged are assumed to be failures of the plugin.
+func ClassifyError(err error) (backend.Status, backend.ErrorSource) {
+	var se *SourcedError
+	if errors.As(err, &se) {
+		return se.Status, se.Source
+	}
+
+	var qe *QueryError
+	if errors.As(err, &qe) {
+		return backend.Status(qe.StatusCode), backend.ErrorSourceDownstream
+	}
+
+	return backend.StatusInternal, backend.ErrorSourcePlugin
+}
+
+// ErrorDataResponse creates a DataResponse for the error with its status and source.
+func ErrorDataResponse(err error) backend.DataResponse {
+	status, source := ClassifyError(err)
+	return backend.DataResponse{
+		Error:       err,
+		Status:      status,
+		ErrorSource: source,
+	}
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorDataResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status backend.Status
+		source backend.ErrorSource
+	}{
+		{
+			name:   "should classify Logship client errors as downstream",
+			err:    NewQueryError(400, "400 Bad Request", &ErrorResponse{Message: "Syntax error"}),
+			status: backend.StatusBadRequest,
+			source: backend.ErrorSourceDownstream,
+		},
+		{
+			name:   "should classify wrapped Logship server errors as downstream",
+			err:    fmt.Errorf("query failed: %w", NewQueryError(503, "503 Service Unavailable", nil)),
+			status: backend.Status(503),
+			source: backend.ErrorSourceDownstream,
+		},
+		{
+			name:   "should classify auth failures as downstream",
+			err:    DownstreamError(backend.StatusUnauthorized, errors.New("failed to authenticate")),
+			status: backend.StatusUnauthorized,
+			source: backend.ErrorSourceDownstream,
+		},
+		{
+			name:   "should classify network failures as downstream",
+			err:    TransportError(&url.Error{Op: "Post", URL: "http://logship", Err: errors.New("connection refused")}),
+			status: backend.StatusBadGateway,
+			source: backend.ErrorSourceDownstream,
+		},
+		{
+			name:   "should classify timeouts as downstream",
+			err:    TransportError(&url.Error{Op: "Post", URL: "http://logship", Err: context.DeadlineExceeded}),
+			status: backend.StatusTimeout,
+			source: backend.ErrorSourceDownstream,
+		},
+		{
+			name:   "should classify interpolation failures as plugin",
+			err:    PluginError(backend.StatusBadRequest, errors.New("failed to interpolate query")),
+			status: backend.StatusBadRequest,
+			source: backend.ErrorSourcePlugin,
+		},
+		{
+			name:   "should classify untagged errors as plugin",
+			err:    errors.New("unsupported analytics column type"),
+			status: backend.StatusInternal,
+			source: backend.ErrorSourcePlugin,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := ErrorDataResponse(tt.err)
+			assert.Equal(t, tt.err, resp.Error)
+			assert.Equal(t, tt.status, resp.Status)
+			assert.Equal(t, tt.source, resp.ErrorSource)
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package models
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// FieldConfigLogshipType is the custom field config key of the Logship column type.
+const FieldConfigLogshipType = "logshipType"
+
+// unitSuffixes map the suffixes of numeric column names to Grafana units.
+var unitSuffixes = []struct {
+	suffix string
+	unit   string
+}{
+	{"_ms", "ms"},
+	{"_millis", "ms"},
+	{"_us", "µs"},
+	{"_ns", "ns"},
+	{"_seconds", "s"},
+	{"_sec", "s"},
+	{"_s", "s"},
+	{"_bytes", "bytes"},
+	{"_pct", "percent"},
+	{"_percent", "percent"},
+}
+
+var annotationRE = regexp.MustCompile(`(?i)\b(unit|decimals|displayName)=("(?:[^"\\]|\\.)*"|\S+)`)
+
+// columnAnnotation is the field config a comment sets for a column.
+type columnAnnotation struct {
+	unit        string
+	displayName string
+	decimals    *uint16
+}
+
+// columnConfig returns the config of a column that follows from its type.
+func columnConfig(typ string, name string, results []map[string]interface{}) *data.FieldConfig {
+	switch typ {
+	case "TimeSpan":
+		return &data.FieldConfig{Unit: "ms"}
+	case "Decimal":
+		decimals := uint16(0)
+		for _, row := range results {
+			var s string
+			switch v := row[name].(type) {
+			case string:
+				s = v
+			case json.Number:
+				s = v.String()
+			}
+			if i := strings.IndexByte(s, '.'); i >= 0 {
+				fraction := s[i+1:]
+				if n := uint16(len(fraction) - len(strings.TrimLeft(fraction, "0123456789"))); n > decimals {
+					decimals = n
+				}
+			}
+		}
+		return &data.FieldConfig{Decimals: &decimals}
+	}
+	return nil
+}
+
+// applyFieldConfig completes the config of the fields of a converted frame:
+//   - Every field has its Logship column type in the logshipType custom config.
+//   - Numeric fields get a unit from the suffix of their name, e.g. Duration_ms or Size_bytes.
+//   - Decimal fields get the number of decimals of their values, TimeSpan fields the ms unit.
+//   - The column annotations of the query override the above, see columnAnnotations.
+func applyFieldConfig(frame *data.Frame, query string) {
+	md, _ := frame.Meta.Custom.(LogshipFrameMD)
+	annotations := columnAnnotations(query)
+
+	for i, f := range frame.Fields {
+		if f.Config == nil {
+			f.Config = &data.FieldConfig{}
+		}
+		if i < len(md.ColumnTypes) {
+			f.Config.Custom = map[string]interface{}{FieldConfigLogshipType: md.ColumnTypes[i]}
+		}
+		if f.Config.Unit == "" && f.Type().Numeric() {
+			f.Config.Unit = unitFromName(f.Name)
+		}
+
+		a, ok := annotations[f.Name]
+		if !ok {
+			continue
+		}
+		if a.unit != "" {
+			f.Config.Unit = a.unit
+		}
+		if a.displayName != "" {
+			f.Config.DisplayName = a.displayName
+		}
+		if a.decimals != nil {
+			f.Config.Decimals = a.decimals
+		}
+	}
+}
+
+func unitFromName(name string) string {
+	lower := strings.ToLower(name)
+	for _, u := range unitSuffixes {
+		if strings.HasSuffix(lower, u.suffix) && len(lower) > len(u.suffix) {
+			return u.unit
+		}
+	}
+	return ""
+}
+
+// columnAnnotations returns the annotations of the columns of the project
+// operators of the query, keyed by column name. A comment annotates the column
+// that precedes it:
+//
+//	| project
+//	    Latency = avg(Duration), // unit=ms decimals=1 displayName="Average latency"
+//	    Size // unit=decbytes
+func columnAnnotations(query string) map[string]columnAnnotation {
+	annotations := map[string]columnAnnotation{}
+	for _, stage := range kqlStages(query) {
+		operator, rest := kqlOperator(stage)
+		if operator != "project" {
+			continue
+		}
+
+		previous := ""
+		for _, part := range splitKQL(rest, ",") {
+			// a comment on the line of the comma belongs to the column before it
+			leading, code := leadingComment(part)
+			if previous != "" && leading != "" {
+				mergeAnnotation(annotations, previous, leading)
+			}
+
+			code, comment := trailingComments(code)
+			previous = projectedName(code)
+			if previous != "" && comment != "" {
+				mergeAnnotation(annotations, previous, comment)
+			}
+		}
+	}
+	return annotations
+}
+
+// leadingComment splits a comment that starts the first line of part from the rest.
+func leadingComment(part string) (string, string) {
+	trimmed := strings.TrimLeft(part, " \t")
+	if !strings.HasPrefix(trimmed, "//") {
+		return "", part
+	}
+	end := strings.IndexByte(trimmed, '\n')
+	if end < 0 {
+		return trimmed[2:], ""
+	}
+	return trimmed[2:end], trimmed[end+1:]
+}
+
+// trailingComments returns the code of part without its comments, and the comments.
+func trailingComments(part string) (string, string) {
+	code := []string{}
+	comments := []string{}
+	for _, line := range strings.Split(part, "\n") {
+		if idx := commentStart(line); idx >= 0 {
+			comments = append(comments, line[idx+2:])
+			line = line[:idx]
+		}
+		code = append(code, line)
+	}
+	return strings.TrimSpace(strings.Join(code, "\n")), strings.Join(comments, " ")
+}
+
+// commentStart returns the index of the // starting a comment in the line, or -1.
+func commentStart(line string) int {
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case strings.HasPrefix(line[i:], "//"):
+			return i
+		case c == '\'' || c == '"':
+			end := kqlStringEnd(line, i, i > 0 && line[i-1] == '@')
+			if end < 0 {
+				return -1
+			}
+			i = end
+		}
+	}
+	return -1
+}
+
+// projectedName returns the name of a projected column: the name assigned to, or
+// the column itself. It is empty for unnamed expressions.
+func projectedName(code string) string {
+	name := code
+	// an empty second part is the == operator of an unnamed expression
+	if parts := splitKQL(code, "="); len(parts) > 1 && parts[1] != "" {
+		name = parts[0]
+	}
+	name = strings.TrimSpace(name)
+	if strings.HasPrefix(name, "[") {
+		return unquoteIdentifier(name)
+	}
+	for i := 0; i < len(name); i++ {
+		if !isIdentByte(name[i]) {
+			return ""
+		}
+	}
+	return name
+}
+
+func mergeAnnotation(annotations map[string]columnAnnotation, column string, comment string) {
+	a := annotations[column]
+	for _, m := range annotationRE.FindAllStringSubmatch(comment, -1) {
+		value := m[2]
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		switch strings.ToLower(m[1]) {
+		case "unit":
+			a.unit = value
+		case "displayname":
+			a.displayName = value
+		case "decimals":
+			if n, err := strconv.ParseUint(value, 10, 16); err == nil {
+				d := uint16(n)
+				a.decimals = &d
+			}
+		}
+	}
+	annotations[column] = a
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withLogshipType adds the Logship column type to the config of the field.
+func withLogshipType(typ string, f *data.Field) *data.Field {
+	if f.Config == nil {
+		f.Config = &data.FieldConfig{}
+	}
+	f.Config.Custom = map[string]interface{}{FieldConfigLogshipType: typ}
+	return f
+}
+
+func TestColumnAnnotations(t *testing.T) {
+	decimals := func(d uint16) *uint16 { return &d }
+
+	tests := []struct {
+		name  string
+		query string
+		want  map[string]columnAnnotation
+	}{
+		{
+			name:  "no project",
+			query: "T | summarize count() // unit=ms",
+			want:  map[string]columnAnnotation{},
+		},
+		{
+			name: "comments after the columns",
+			query: `Requests
+| summarize avg(Duration), sum(Size) by bin(Timestamp, 1m)
+| project
+    Timestamp,
+    Latency = avg_Duration, // unit=ms decimals=1 displayName="Average latency"
+    ['Total size'] = sum_Size // unit=decbytes
+| where Latency > 0`,
+			want: map[string]columnAnnotation{
+				"Latency":    {unit: "ms", displayName: "Average latency", decimals: decimals(1)},
+				"Total size": {unit: "decbytes"},
+			},
+		},
+		{
+			name:  "comments on a single line",
+			query: `T | project a, b = strcat("//", c) // unit=short DECIMALS=2`,
+			want: map[string]columnAnnotation{
+				"b": {unit: "short", decimals: decimals(2)},
+			},
+		},
+		{
+			name: "comments without annotations and unnamed expressions",
+			query: `T | project
+    x == 1, // unit=ms
+    y // the y value, in bytes`,
+			want: map[string]columnAnnotation{
+				"y": {},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, columnAnnotations(tt.query))
+		})
+	}
+}
+
+func TestFieldConfig(t *testing.T) {
+	tr, err := TableFromJSON(strings.NewReader(`{
+		"Columns": [
+			{"Name": "Duration_ms", "Type": "Float64"},
+			{"Name": "Size_bytes", "Type": "Int64"},
+			{"Name": "Error_pct", "Type": "String"},
+			{"Name": "Price", "Type": "Decimal"},
+			{"Name": "Elapsed", "Type": "TimeSpan"},
+			{"Name": "Latency", "Type": "Float64"}
+		],
+		"Results": [
+			{"Duration_ms": 1, "Size_bytes": 2, "Error_pct": "3", "Price": "1.50", "Elapsed": "00:00:01", "Latency": 4},
+			{"Duration_ms": 1, "Size_bytes": 2, "Error_pct": "3", "Price": 2.125, "Elapsed": null, "Latency": 4}
+		]
+	}`))
+	require.NoError(t, err)
+
+	frames, err := tr.ToDataFrames("T | project Duration_ms, Size_bytes, Error_pct, Price, Elapsed, Latency // unit=µs displayName=Latency", FrameOptions{})
+	require.NoError(t, err)
+
+	configs := []*data.FieldConfig{}
+	for _, f := range frames[0].Fields {
+		configs = append(configs, f.Config)
+	}
+	three := uint16(3)
+	require.Equal(t, []*data.FieldConfig{
+		{Unit: "ms", Custom: map[string]interface{}{FieldConfigLogshipType: "Float64"}},
+		{Unit: "bytes", Custom: map[string]interface{}{FieldConfigLogshipType: "Int64"}},
+		{Custom: map[string]interface{}{FieldConfigLogshipType: "String"}},
+		{Decimals: &three, Custom: map[string]interface{}{FieldConfigLogshipType: "Decimal"}},
+		{Unit: "ms", Custom: map[string]interface{}{FieldConfigLogshipType: "TimeSpan"}},
+		{Unit: "µs", DisplayName: "Latency", Custom: map[string]interface{}{FieldConfigLogshipType: "Float64"}},
+	}, configs)
+}
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// flattenFields returns a field per key of the objects of a Dynamic column, named
+// column.key, with the keys of nested objects flattened up to depth levels. The
+// type of a field is inferred from its values: numbers, booleans and RFC 3339
+// strings become number, bool and time fields, anything else a string field with
+// arrays and objects as JSON. It returns nil when any value is not an object, when
+// the objects have no keys or when keys collide once flattened.
+func flattenFields(name string, values []interface{}, depth int) ([]*data.Field, []string) {
+	rows := make([]map[string]interface{}, len(values))
+	paths := map[string]string{}
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+		rows[i] = map[string]interface{}{}
+		if !flattenObject(name, name, object, depth, rows[i], paths) {
+			return nil, nil
+		}
+	}
+
+	keys := []string{}
+	seen := map[string]bool{}
+	for _, row := range rows {
+		for k := range row {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	sort.Strings(keys)
+
+	fields := make([]*data.Field, 0, len(keys))
+	types := make([]string, 0, len(keys))
+	column := make([]interface{}, len(rows))
+	for _, k := range keys {
+		for i, row := range rows {
+			column[i] = row[k]
+		}
+		f, typ := inferredField(k, column)
+		fields = append(fields, f)
+		types = append(types, typ)
+	}
+	return fields, types
+}
+
+// flattenObject adds the values of the object to flat, keyed by their dotted path.
+// paths records the keys each dotted path was reached by, and it returns false when
+// a dotted path is reached by other keys, e.g. {"a.b": 1} and {"a": {"b": 2}}.
+func flattenObject(prefix string, path string, object map[string]interface{}, depth int, flat map[string]interface{}, paths map[string]string) bool {
+	for k, v := range object {
+		key, keyPath := prefix+"."+k, path+"\x00"+k
+		if nested, ok := v.(map[string]interface{}); ok && depth > 1 {
+			if !flattenObject(key, keyPath, nested, depth-1, flat, paths) {
+				return false
+			}
+			continue
+		}
+		if p, ok := paths[key]; ok && p != keyPath {
+			return false
+		}
+		paths[key] = keyPath
+		flat[key] = v
+	}
+	return true
+}
+
+// inferredField returns a field of the values with the type they all share, and
+// the column type it corresponds to.
+func inferredField(name string, values []interface{}) (*data.Field, string) {
+	numbers, bools, times := true, true, true
+	for _, v := range values {
+		switch s := v.(type) {
+		case nil:
+		case json.Number:
+			bools, times = false, false
+		case bool:
+			numbers, times = false, false
+		case string:
+			numbers, bools = false, false
+			if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+				times = false
+			}
+		default:
+			numbers, bools, times = false, false, false
+		}
+	}
+
+	switch {
+	case numbers && bools && times:
+		// only nulls
+	case numbers:
+		out := make([]*float64, len(values))
+		for i, v := range values {
+			if n, ok := v.(json.Number); ok {
+				if f, err := n.Float64(); err == nil {
+					out[i] = &f
+				}
+			}
+		}
+		return data.NewField(name, nil, out), "Float64"
+	case bools:
+		out := make([]*bool, len(values))
+		for i, v := range values {
+			if b, ok := v.(bool); ok {
+				out[i] = &b
+			}
+		}
+		return data.NewField(name, nil, out), "Boolean"
+	case times:
+		out := make([]*time.Time, len(values))
+		for i, v := range values {
+			if s, ok := v.(string); ok {
+				t, _ := time.Parse(time.RFC3339Nano, s)
+				out[i] = &t
+			}
+		}
+		return data.NewField(name, nil, out), "DateTime"
+	}
+
+	out := make([]*string, len(values))
+	for i, v := range values {
+		switch s := v.(type) {
+		case nil:
+		case string:
+			out[i] = &s
+		default:
+			// sorted keys, so that equal objects have equal strings
+			b, err := jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(v)
+			if err == nil {
+				str := string(b)
+				out[i] = &str
+			}
+		}
+	}
+	return data.NewField(name, nil, out), "String"
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xorcare/pointer"
+)
+
+func TestFlattenDynamicColumns(t *testing.T) {
+	tr, err := TableFromJSON(strings.NewReader(`{
+		"Columns": [
+			{"Name": "Name", "Type": "String"},
+			{"Name": "customDimensions", "Type": "Dynamic"},
+			{"Name": "List", "Type": "Dynamic"}
+		],
+		"Results": [
+			{"Name": "a", "customDimensions": {"count": 2, "ok": true, "at": "2023-01-01T10:00:00Z", "tags": ["x"], "http": {"status": 200}}, "List": [1]},
+			{"Name": "b", "customDimensions": null, "List": null},
+			{"Name": "c", "customDimensions": {"count": 1.5, "ok": false, "at": "soon", "http": {"status": 404, "path": "/"}}, "List": [2]}
+		]
+	}`))
+	require.NoError(t, err)
+	at := time.Date(2023, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		depth   int
+		errorIs assert.ErrorAssertionFunc
+		fields  []*data.Field
+		types   []string
+	}{
+		{
+			name:    "not flattened by default",
+			errorIs: assert.NoError,
+			types:   []string{"String", "Dynamic", "Dynamic"},
+		},
+		{
+			name:    "top level keys",
+			depth:   1,
+			errorIs: assert.NoError,
+			fields: []*data.Field{
+				data.NewField("Name", nil, []*string{pointer.String("a"), pointer.String("b"), pointer.String("c")}),
+				data.NewField("customDimensions.at", nil, []*string{pointer.String("2023-01-01T10:00:00Z"), nil, pointer.String("soon")}),
+				data.NewField("customDimensions.count", nil, []*float64{pointer.Float64(2), nil, pointer.Float64(1.5)}),
+				data.NewField("customDimensions.http", nil, []*string{pointer.String(`{"status":200}`), nil, pointer.String(`{"path":"/","status":404}`)}),
+				data.NewField("customDimensions.ok", nil, []*bool{pointer.Bool(true), nil, pointer.Bool(false)}),
+				data.NewField("customDimensions.tags", nil, []*string{pointer.String(`["x"]`), nil, nil}),
+				data.NewField("List", nil, []string{`[1]`, `null`, `[2]`}),
+			},
+			types: []string{"String", "String", "Float64", "String", "Boolean", "String", "Dynamic"},
+		},
+		{
+			name:    "nested keys",
+			depth:   2,
+			errorIs: assert.NoError,
+			fields: []*data.Field{
+				data.NewField("Name", nil, []*string{pointer.String("a"), pointer.String("b"), pointer.String("c")}),
+				data.NewField("customDimensions.at", nil, []*string{pointer.String("2023-01-01T10:00:00Z"), nil, pointer.String("soon")}),
+				data.NewField("customDimensions.count", nil, []*float64{pointer.Float64(2), nil, pointer.Float64(1.5)}),
+				data.NewField("customDimensions.http.path", nil, []*string{nil, nil, pointer.String("/")}),
+				data.NewField("customDimensions.http.status", nil, []*float64{pointer.Float64(200), nil, pointer.Float64(404)}),
+				data.NewField("customDimensions.ok", nil, []*bool{pointer.Bool(true), nil, pointer.Bool(false)}),
+				data.NewField("customDimensions.tags", nil, []*string{pointer.String(`["x"]`), nil, nil}),
+				data.NewField("List", nil, []string{`[1]`, `null`, `[2]`}),
+			},
+			types: []string{"String", "String", "Float64", "String", "Float64", "Boolean", "String", "Dynamic"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames, err := tr.ToDataFrames("", FrameOptions{FlattenDepth: tt.depth})
+			tt.errorIs(t, err)
+			if tt.types != nil {
+				require.Len(t, frames, 1)
+				require.Equal(t, tt.types, frames[0].Meta.Custom.(LogshipFrameMD).ColumnTypes)
+			}
+			if tt.fields != nil {
+				for i, f := range tt.fields {
+					withLogshipType(tt.types[i], f)
+				}
+				require.Equal(t, tt.fields, frames[0].Fields)
+			}
+		})
+	}
+
+	t.Run("time values", func(t *testing.T) {
+		tr, err := TableFromJSON(strings.NewReader(`{
+			"Columns": [{"Name": "d", "Type": "Dynamic"}],
+			"Results": [{"d": {"at": "2023-01-01T10:00:00Z"}}, {"d": {}}]
+		}`))
+		require.NoError(t, err)
+		frames, err := tr.ToDataFrames("", FrameOptions{FlattenDepth: 1})
+		require.NoError(t, err)
+		require.Equal(t, []*data.Field{withLogshipType("DateTime", data.NewField("d.at", nil, []*time.Time{&at, nil}))}, frames[0].Fields)
+	})
+
+	unflattened := []struct {
+		name    string
+		results string
+	}{
+		{name: "objects without keys", results: `[{"d": {}}, {"d": null}]`},
+		{name: "keys colliding within an object", results: `[{"d": {"a.b": 1, "a": {"b": 2}}}]`},
+		{name: "keys colliding across rows", results: `[{"d": {"a.b": 1}}, {"d": {"a": {"b": 2}}}]`},
+	}
+	for _, tt := range unflattened {
+		t.Run(tt.name+" are not flattened", func(t *testing.T) {
+			tr, err := TableFromJSON(strings.NewReader(`{"Columns": [{"Name": "d", "Type": "Dynamic"}], "Results": ` + tt.results + `}`))
+			require.NoError(t, err)
+			frames, err := tr.ToDataFrames("", FrameOptions{FlattenDepth: 2})
+			require.NoError(t, err)
+			require.Len(t, frames[0].Fields, 1)
+			require.Equal(t, "d", frames[0].Fields[0].Name)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// columnFinder picks the fields of a frame that a result format is built from,
+// using every field at most once.
+type columnFinder struct {
+	frame *data.Frame
+	used  map[*data.Field]bool
+}
+
+func newColumnFinder(frame *data.Frame) *columnFinder {
+	return &columnFinder{frame: frame, used: map[*data.Field]bool{}}
+}
+
+// find returns the field named by option when it is set, otherwise the first unused
+// field whose name case-insensitively matches one of the names, in order of preference.
+// It returns nil when no field matches.
+func (c *columnFinder) find(option string, names []string, accept func(*data.Field) bool) (*data.Field, error) {
+	if option != "" {
+		f, idx := c.frame.FieldByName(option)
+		if idx < 0 {
+			return nil, fmt.Errorf("column %q not found", option)
+		}
+		if !accept(f) {
+			return nil, fmt.Errorf("column %q has the unsupported type %s", option, f.Type().ItemTypeString())
+		}
+		c.used[f] = true
+		return f, nil
+	}
+
+	for _, name := range names {
+		for _, f := range c.frame.Fields {
+			if !c.used[f] && strings.EqualFold(f.Name, name) && accept(f) {
+				c.used[f] = true
+				return f, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// first returns the first unused field that accept accepts, or nil.
+func (c *columnFinder) first(accept func(*data.Field) bool) *data.Field {
+	for _, f := range c.frame.Fields {
+		if !c.used[f] && accept(f) {
+			c.used[f] = true
+			return f
+		}
+	}
+	return nil
+}
+
+// unused returns the fields that have not been picked.
+func (c *columnFinder) unused() []*data.Field {
+	fields := []*data.Field{}
+	for _, f := range c.frame.Fields {
+		if !c.used[f] {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+func isTimeField(f *data.Field) bool {
+	return f.Type().Time()
+}
+
+func isNumericField(f *data.Field) bool {
+	return f.Type().Numeric()
+}
+
+func isStringField(f *data.Field) bool {
+	return f.Type().NonNullableType() == data.FieldTypeString
+}
+
+func anyField(*data.Field) bool {
+	return true
+}
+
+func timeAt(f *data.Field, i int) (time.Time, bool) {
+	v, ok := f.ConcreteAt(i)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, ok := v.(time.Time)
+	return t, ok
+}
+
+func stringAt(f *data.Field, i int) string {
+	if f == nil {
+		return ""
+	}
+	v, ok := f.ConcreteAt(i)
+	if !ok {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+// floatAt returns the value of a numeric field as a float64.
+func floatAt(f *data.Field, i int) (float64, bool) {
+	if f == nil {
+		return 0, false
+	}
+	v, err := f.NullableFloatAt(i)
+	if err != nil || v == nil {
+		return 0, false
+	}
+	return *v, true
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// nullableField returns a field of the nullable type of its first value that is not
+// nil, like the fields ToDataFrames returns. It is the fixture the structured
+// formats are reshaped from.
+func nullableField(name string, values ...interface{}) *data.Field {
+	fieldType := data.FieldTypeNullableString
+	for _, v := range values {
+		if v != nil {
+			fieldType = data.FieldTypeFor(v).NullableType()
+			break
+		}
+	}
+
+	field := data.NewFieldFromFieldType(fieldType, len(values))
+	field.Name = name
+	for i, v := range values {
+		if v != nil {
+			field.SetConcrete(i, v)
+		}
+	}
+	return field
+}
@@ -0,0 +1,152 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//  Saved query functions:
+//   - $__fn(name, arg1, arg2) -> the body of the saved function with its {parameter}
+//     placeholders replaced by the arguments.
+
+// maxFunctionDepth limits how deeply saved functions may call each other.
+const maxFunctionDepth = 10
+
+// QueryFunction is a named, parameterized query fragment stored in the datasource settings.
+type QueryFunction struct {
+	Name        string   `json:"name"`
+	Parameters  []string `json:"parameters"`
+	Body        string   `json:"body"`
+	Description string   `json:"description"`
+}
+
+// FunctionSchema describes a function that can be used in queries.
+type FunctionSchema struct {
+	Name            string         `json:"Name"`
+	Body            string         `json:"Body"`
+	FunctionKind    string         `json:"FunctionKind"`
+	InputParameters []ColumnSchema `json:"InputParameters"`
+	OutputColumns   []ColumnSchema `json:"OutputColumns"`
+	DocString       string         `json:"DocString,omitempty"`
+}
+
+const savedFunctionKind = "SavedQuery"
+
+var functionNameRE = regexp.MustCompile(`^[A-Za-z_][\w]*$`)
+
+// validateQueryFunctions checks that every function and parameter has a valid, unique name.
+func validateQueryFunctions(fns []QueryFunction) error {
+	seen := map[string]bool{}
+	for _, fn := range fns {
+		if !functionNameRE.MatchString(fn.Name) {
+			return fmt.Errorf("invalid query function name %q", fn.Name)
+		}
+		if seen[fn.Name] {
+			return fmt.Errorf("duplicate query function name %q", fn.Name)
+		}
+		seen[fn.Name] = true
+
+		params := map[string]bool{}
+		for _, p := range fn.Parameters {
+			if !functionNameRE.MatchString(p) || params[p] {
+				return fmt.Errorf("invalid or duplicate parameter %q in query function %q", p, fn.Name)
+			}
+			params[p] = true
+		}
+	}
+	return nil
+}
+
+// SavedFunctionSchemas returns the saved query functions sorted by name.
+func SavedFunctionSchemas(fns []QueryFunction) []FunctionSchema {
+	schemas := make([]FunctionSchema, 0, len(fns))
+	for _, fn := range fns {
+		params := make([]ColumnSchema, 0, len(fn.Parameters))
+		for _, p := range fn.Parameters {
+			params = append(params, ColumnSchema{Name: p})
+		}
+		schemas = append(schemas, FunctionSchema{
+			Name:            fn.Name,
+			Body:            fn.Body,
+			FunctionKind:    savedFunctionKind,
+			InputParameters: params,
+			OutputColumns:   []ColumnSchema{},
+			DocString:       fn.Description,
+		})
+	}
+	sort.Slice(schemas, func(i, j int) bool { return schemas[i].Name < schemas[j].Name })
+	return schemas
+}
+
+// ExpandFunctions replaces $__fn calls with the bodies of the saved functions,
+// including calls made from within function bodies.
+func ExpandFunctions(query string, fns []QueryFunction) (string, error) {
+	byName := make(map[string]QueryFunction, len(fns))
+	for _, fn := range fns {
+		byName[fn.Name] = fn
+	}
+
+	for depth := 0; ; depth++ {
+		calls := outermostCalls(kqlCalls(query, "$__fn"))
+		if len(calls) == 0 {
+			return query, nil
+		}
+		if depth >= maxFunctionDepth {
+			return "", fmt.Errorf("failed to interpolate query, $__fn calls are nested more than %d levels deep, check for recursive query functions", maxFunctionDepth)
+		}
+
+		var b strings.Builder
+		last := 0
+		for _, call := range calls {
+			body, err := callFunction(call, byName)
+			if err != nil {
+				return "", fmt.Errorf("failed to interpolate query, errors: %w", err)
+			}
+			b.WriteString(query[last:call.Start])
+			b.WriteString(body)
+			last = call.End
+		}
+		b.WriteString(query[last:])
+		query = b.String()
+	}
+}
+
+// outermostCalls drops calls nested in the arguments of other calls, which are
+// expanded once their arguments have been substituted.
+func outermostCalls(calls []kqlCall) []kqlCall {
+	outer := []kqlCall{}
+	end := -1
+	for _, call := range calls {
+		if call.Start < end {
+			continue
+		}
+		outer = append(outer, call)
+		end = call.End
+	}
+	return outer
+}
+
+func callFunction(call kqlCall, fns map[string]QueryFunction) (string, error) {
+	if len(call.Args) == 0 {
+		return "", fmt.Errorf("$__fn requires a function name")
+	}
+
+	name := unquoteIdentifier(call.Args[0])
+	fn, ok := fns[name]
+	if !ok {
+		return "", fmt.Errorf("unknown query function %q", name)
+	}
+
+	args := call.Args[1:]
+	if len(args) != len(fn.Parameters) {
+		return "", fmt.Errorf("query function %q expects %d arguments (%s) but got %d", name, len(fn.Parameters), strings.Join(fn.Parameters, ", "), len(args))
+	}
+
+	replacements := make([]string, 0, len(args)*2)
+	for i, p := range fn.Parameters {
+		replacements = append(replacements, "{"+p+"}", args[i])
+	}
+	return strings.NewReplacer(replacements...).Replace(fn.Body), nil
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMacroData_InterpolateFunctions(t *testing.T) {
+	fns := []QueryFunction{
+		{
+			Name:       "errorRate",
+			Parameters: []string{"service"},
+			Body:       `Requests | where Service == {service} | summarize errors = countif(Status >= 500) / count()`,
+		},
+		{
+			Name:       "services",
+			Parameters: []string{"region", "limit"},
+			Body:       `Services | where Region == {region} | take {limit}`,
+		},
+		{
+			Name: "checkout",
+			Body: `$__fn(errorRate, "checkout")`,
+		},
+		{
+			Name: "loop",
+			Body: `$__fn(loop)`,
+		},
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		errorIs   require.ErrorAssertionFunc
+		returnVal string
+	}{
+		{
+			name:      "should expand a function",
+			query:     `$__fn(errorRate, "checkout")`,
+			errorIs:   require.NoError,
+			returnVal: `Requests | where Service == "checkout" | summarize errors = countif(Status >= 500) / count()`,
+		},
+		{
+			name:      "should expand arguments containing commas and parentheses",
+			query:     `$__fn(services, strcat("us", ",", "east"), 10) | project Name`,
+			errorIs:   require.NoError,
+			returnVal: `Services | where Region == strcat("us", ",", "east") | take 10 | project Name`,
+		},
+		{
+			name:      "should expand nested functions",
+			query:     `$__fn(checkout)`,
+			errorIs:   require.NoError,
+			returnVal: `Requests | where Service == "checkout" | summarize errors = countif(Status >= 500) / count()`,
+		},
+		{
+			name:      "should expand functions used as arguments",
+			query:     `$__fn(services, "eu", toscalar($__fn(services, "us", 1) | count))`,
+			errorIs:   require.NoError,
+			returnVal: `Services | where Region == "eu" | take toscalar(Services | where Region == "us" | take 1 | count)`,
+		},
+		{
+			name:      "should not expand functions in strings",
+			query:     `T | where Message == "$__fn(errorRate)"`,
+			errorIs:   require.NoError,
+			returnVal: `T | where Message == "$__fn(errorRate)"`,
+		},
+		{
+			name:    "should fail for unknown functions",
+			query:   `$__fn(missing)`,
+			errorIs: require.Error,
+		},
+		{
+			name:    "should fail for the wrong number of arguments",
+			query:   `$__fn(errorRate)`,
+			errorIs: require.Error,
+		},
+		{
+			name:    "should fail for recursive functions",
+			query:   `$__fn(loop)`,
+			errorIs: require.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			val, err := ExpandFunctions(tt.query, fns)
+			tt.errorIs(t, err)
+			assert.Equal(t, tt.returnVal, val)
+		})
+	}
+
+	t.Run("should expand functions before other macros", func(t *testing.T) {
+		val, err := ExpandFunctions("$__fn(binned)", []QueryFunction{
+			{Name: "binned", Body: "T | summarize count() by bin(Timestamp, $__timeInterval)"},
+		})
+		require.NoError(t, err)
+		val, err = NewMacroData(nil, 12).Interpolate(val)
+		require.NoError(t, err)
+		assert.Equal(t, "T | summarize count() by bin(Timestamp, 12ms)", val)
+	})
+}
+
+func TestValidateQueryFunctions(t *testing.T) {
+	assert.NoError(t, validateQueryFunctions([]QueryFunction{{Name: "a", Parameters: []string{"x", "y"}}, {Name: "b"}}))
+	assert.Error(t, validateQueryFunctions([]QueryFunction{{Name: "a"}, {Name: "a"}}))
+	assert.Error(t, validateQueryFunctions([]QueryFunction{{Name: "not valid"}}))
+	assert.Error(t, validateQueryFunctions([]QueryFunction{{Name: "a", Parameters: []string{"x", "x"}}}))
+}
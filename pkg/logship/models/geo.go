@@ -0,0 +1,249 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	// GeoModeNone leaves GeoJSON values as JSON strings.
+	GeoModeNone = "none"
+	// GeoModeCoordinates adds latitude and longitude fields for GeoJSON columns.
+	GeoModeCoordinates = "coords"
+	// GeoModeGeohash adds a geohash field for GeoJSON columns.
+	GeoModeGeohash = "geohash"
+)
+
+const (
+	defaultGeohashPrecision = 12
+	geohashAlphabet         = "0123456789bcdefghjkmnpqrstuvwxyz"
+)
+
+// geoJSONTypes are the GeoJSON geometry types that Dynamic values are recognized as.
+var geoJSONTypes = map[string]bool{
+	"Point":           true,
+	"MultiPoint":      true,
+	"LineString":      true,
+	"MultiLineString": true,
+	"Polygon":         true,
+	"MultiPolygon":    true,
+}
+
+// geoPoint is a position in degrees.
+type geoPoint struct {
+	lat float64
+	lon float64
+}
+
+// geoJSONPoint returns the position of a GeoJSON geometry or feature: the point of a
+// Point, and the center of the vertices of any other geometry.
+func geoJSONPoint(v interface{}) (geoPoint, bool) {
+	if s, ok := v.(string); ok {
+		if err := jsoniter.UnmarshalFromString(s, &v); err != nil {
+			return geoPoint{}, false
+		}
+	}
+	object, ok := v.(map[string]interface{})
+	if !ok {
+		return geoPoint{}, false
+	}
+	if object["type"] == "Feature" {
+		return geoJSONPoint(object["geometry"])
+	}
+	typ, _ := object["type"].(string)
+	if !geoJSONTypes[typ] {
+		return geoPoint{}, false
+	}
+	if typ == "Polygon" || typ == "MultiPolygon" {
+		return outerRingCenter(object["coordinates"], typ == "MultiPolygon")
+	}
+
+	sum, n := geoPoint{}, 0
+	ok = walkPositions(object["coordinates"], func(p geoPoint) {
+		sum.lat += p.lat
+		sum.lon += p.lon
+		n++
+	})
+	if !ok || n == 0 {
+		return geoPoint{}, false
+	}
+	return geoPoint{lat: sum.lat / float64(n), lon: sum.lon / float64(n)}, true
+}
+
+// outerRingCenter returns the center of the vertices of the outer rings of a
+// polygon, leaving out the closing vertex of every ring.
+func outerRingCenter(coordinates interface{}, multi bool) (geoPoint, bool) {
+	polygons := []interface{}{coordinates}
+	if multi {
+		polygons, _ = coordinates.([]interface{})
+	}
+
+	sum, n := geoPoint{}, 0
+	for _, polygon := range polygons {
+		rings, ok := polygon.([]interface{})
+		if !ok || len(rings) == 0 {
+			return geoPoint{}, false
+		}
+		ring, ok := rings[0].([]interface{})
+		if !ok {
+			return geoPoint{}, false
+		}
+		if len(ring) > 1 {
+			ring = ring[:len(ring)-1]
+		}
+		if !walkPositions(ring, func(p geoPoint) {
+			sum.lat += p.lat
+			sum.lon += p.lon
+			n++
+		}) {
+			return geoPoint{}, false
+		}
+	}
+	if n == 0 {
+		return geoPoint{}, false
+	}
+	return geoPoint{lat: sum.lat / float64(n), lon: sum.lon / float64(n)}, true
+}
+
+// walkPositions calls fn with every [longitude, latitude] position of nested
+// GeoJSON coordinates. It returns false when the coordinates are invalid.
+func walkPositions(coordinates interface{}, fn func(geoPoint)) bool {
+	list, ok := coordinates.([]interface{})
+	if !ok {
+		return false
+	}
+	if len(list) >= 2 {
+		lon, lonOk := geoNumber(list[0])
+		lat, latOk := geoNumber(list[1])
+		if lonOk && latOk {
+			if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+				return false
+			}
+			fn(geoPoint{lat: lat, lon: lon})
+			return true
+		}
+	}
+	for _, c := range list {
+		if !walkPositions(c, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+func geoNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// Geohash encodes the position as a geohash with the number of characters.
+func Geohash(lat float64, lon float64, precision int) string {
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+
+	var hash strings.Builder
+	bit, ch, even := 0, 0, true
+	for hash.Len() < precision {
+		r, v := &latRange, lat
+		if even {
+			r, v = &lonRange, lon
+		}
+		mid := (r[0] + r[1]) / 2
+		if v >= mid {
+			ch = ch<<1 | 1
+			r[0] = mid
+		} else {
+			ch <<= 1
+			r[1] = mid
+		}
+		even = !even
+
+		if bit++; bit == 5 {
+			hash.WriteByte(geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return hash.String()
+}
+
+// geoFieldNames returns the names of the fields the geo mode adds, without prefix.
+func geoFieldNames(mode string) []string {
+	switch mode {
+	case GeoModeCoordinates:
+		return []string{"latitude", "longitude"}
+	case GeoModeGeohash:
+		return []string{"geohash"}
+	}
+	return nil
+}
+
+// geoFields returns the fields that the GeoJSON values of a Dynamic column expand
+// to, or nil when the column has no GeoJSON values or values of any other kind.
+// The fields are named latitude and longitude, or geohash, prefixed with the column
+// name when prefix is set. The column types of the fields are returned with them.
+func geoFields(name string, values []interface{}, opts FrameOptions, prefix bool) ([]*data.Field, []string) {
+	if opts.GeoMode != GeoModeCoordinates && opts.GeoMode != GeoModeGeohash {
+		return nil, nil
+	}
+
+	points := make([]*geoPoint, len(values))
+	found := false
+	for i, v := range values {
+		if v == nil {
+			continue
+		}
+		p, ok := geoJSONPoint(v)
+		if !ok {
+			return nil, nil
+		}
+		points[i], found = &p, true
+	}
+	if !found {
+		return nil, nil
+	}
+
+	fieldName := func(s string) string {
+		if prefix {
+			return name + "_" + s
+		}
+		return s
+	}
+
+	switch opts.GeoMode {
+	case GeoModeCoordinates:
+		lats := make([]*float64, len(points))
+		lons := make([]*float64, len(points))
+		for i, p := range points {
+			if p != nil {
+				lats[i], lons[i] = &p.lat, &p.lon
+			}
+		}
+		return []*data.Field{
+			data.NewField(fieldName("latitude"), nil, lats),
+			data.NewField(fieldName("longitude"), nil, lons),
+		}, []string{"Float64", "Float64"}
+	case GeoModeGeohash:
+		precision := opts.GeohashPrecision
+		if precision == 0 {
+			precision = defaultGeohashPrecision
+		}
+		hashes := make([]*string, len(points))
+		for i, p := range points {
+			if p != nil {
+				h := Geohash(p.lat, p.lon, precision)
+				hashes[i] = &h
+			}
+		}
+		return []*data.Field{data.NewField(fieldName("geohash"), nil, hashes)}, []string{"String"}
+	}
+	return nil, nil
+}
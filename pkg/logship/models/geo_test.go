@@ -0,0 +1,109 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xorcare/pointer"
+)
+
+func TestGeohash(t *testing.T) {
+	require.Equal(t, "u4pruydqqvj", Geohash(57.64911, 10.40744, 11))
+	require.Equal(t, "s0000", Geohash(0, 0, 5))
+	require.Equal(t, "7zzzz", Geohash(-0.0001, -0.0001, 5))
+}
+
+func TestGeoColumns(t *testing.T) {
+	response := func(geo string) *TableResponse {
+		tr, err := TableFromJSON(strings.NewReader(`{
+			"Columns": [{"Name": "Name", "Type": "String"}, {"Name": "Location", "Type": "Dynamic"}],
+			"Results": [
+				{"Name": "a", "Location": ` + geo + `},
+				{"Name": "b", "Location": null},
+				{"Name": "c", "Location": {"type": "Feature", "geometry": {"type": "Point", "coordinates": [10.40744, 57.64911]}}}
+			]
+		}`))
+		require.NoError(t, err)
+		return tr
+	}
+	point := `{"type": "Point", "coordinates": [-0.1276, 51.5072]}`
+	polygon := `{"type": "Polygon", "coordinates": [[[0, 0], [4, 0], [4, 2], [0, 2], [0, 0]], [[1, 1], [2, 1], [2, 1.5], [1, 1]]]}`
+
+	tests := []struct {
+		name    string
+		geo     string
+		opts    FrameOptions
+		errorIs assert.ErrorAssertionFunc
+		fields  []*data.Field // fields after Name and Location
+	}{
+		{
+			name:    "not expanded by default",
+			geo:     point,
+			errorIs: assert.NoError,
+			fields:  []*data.Field{},
+		},
+		{
+			name:    "point coordinates",
+			geo:     point,
+			opts:    FrameOptions{GeoMode: GeoModeCoordinates},
+			errorIs: assert.NoError,
+			fields: []*data.Field{
+				withLogshipType("Float64", data.NewField("latitude", nil, []*float64{pointer.Float64(51.5072), nil, pointer.Float64(57.64911)})),
+				withLogshipType("Float64", data.NewField("longitude", nil, []*float64{pointer.Float64(-0.1276), nil, pointer.Float64(10.40744)})),
+			},
+		},
+		{
+			name:    "center of a polygon",
+			geo:     polygon,
+			opts:    FrameOptions{GeoMode: GeoModeCoordinates},
+			errorIs: assert.NoError,
+			fields: []*data.Field{
+				withLogshipType("Float64", data.NewField("latitude", nil, []*float64{pointer.Float64(1), nil, pointer.Float64(57.64911)})),
+				withLogshipType("Float64", data.NewField("longitude", nil, []*float64{pointer.Float64(2), nil, pointer.Float64(10.40744)})),
+			},
+		},
+		{
+			name:    "geohash of a JSON string",
+			geo:     `"{\"type\": \"Point\", \"coordinates\": [10.40744, 57.64911]}"`,
+			opts:    FrameOptions{GeoMode: GeoModeGeohash, GeohashPrecision: 11},
+			errorIs: assert.NoError,
+			fields: []*data.Field{
+				withLogshipType("String", data.NewField("geohash", nil, []*string{pointer.String("u4pruydqqvj"), nil, pointer.String("u4pruydqqvj")})),
+			},
+		},
+		{
+			name:    "other dynamic values",
+			geo:     `{"type": "Point", "coordinates": "here"}`,
+			opts:    FrameOptions{GeoMode: GeoModeCoordinates},
+			errorIs: assert.NoError,
+			fields:  []*data.Field{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames, err := response(tt.geo).ToDataFrames("", tt.opts)
+			tt.errorIs(t, err)
+			if tt.fields != nil {
+				require.Len(t, frames, 1)
+				require.Equal(t, tt.fields, frames[0].Fields[2:])
+			}
+		})
+	}
+
+	t.Run("fields are prefixed when a column has their name", func(t *testing.T) {
+		tr, err := TableFromJSON(strings.NewReader(`{
+			"Columns": [{"Name": "geohash", "Type": "String"}, {"Name": "Location", "Type": "Dynamic"}],
+			"Results": [{"geohash": "u4", "Location": ` + point + `}]
+		}`))
+		require.NoError(t, err)
+
+		frames, err := tr.ToDataFrames("", FrameOptions{GeoMode: GeoModeGeohash, GeohashPrecision: 5})
+		require.NoError(t, err)
+		require.Equal(t, "geohash", frames[0].Fields[0].Name)
+		require.Equal(t, withLogshipType("String", data.NewField("Location_geohash", nil, []*string{pointer.String("gcpvj")})), frames[0].Fields[2])
+	})
+}
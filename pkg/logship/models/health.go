@@ -0,0 +1,51 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	HealthStepOk      = "ok"
+	HealthStepError   = "error"
+	HealthStepSkipped = "skipped"
+)
+
+// HealthStep is the result of a single step of a health check.
+type HealthStep struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Message   string  `json:"message,omitempty"`
+	LatencyMS float64 `json:"latencyMs"`
+}
+
+// HealthReport collects the steps of a health check. Once a step fails,
+// the following steps are skipped since they depend on it.
+type HealthReport struct {
+	Steps []HealthStep `json:"steps"`
+	err   error
+}
+
+// Run executes the step and records its message, latency and outcome.
+func (r *HealthReport) Run(name string, step func() (string, error)) {
+	if r.err != nil {
+		r.Steps = append(r.Steps, HealthStep{Name: name, Status: HealthStepSkipped})
+		return
+	}
+
+	start := time.Now()
+	message, err := step()
+	latency := float64(time.Since(start).Microseconds()) / 1000
+
+	if err != nil {
+		r.err = fmt.Errorf("%s failed: %w", name, err)
+		r.Steps = append(r.Steps, HealthStep{Name: name, Status: HealthStepError, Message: err.Error(), LatencyMS: latency})
+		return
+	}
+	r.Steps = append(r.Steps, HealthStep{Name: name, Status: HealthStepOk, Message: message, LatencyMS: latency})
+}
+
+// Err returns the error of the step that failed, if any.
+func (r *HealthReport) Err() error {
+	return r.err
+}
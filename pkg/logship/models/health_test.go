@@ -0,0 +1,39 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthReport(t *testing.T) {
+	t.Run("all steps succeed", func(t *testing.T) {
+		report := &HealthReport{}
+		report.Run("connectivity", func() (string, error) { return "HTTP 200 OK", nil })
+		report.Run("whoami", func() (string, error) { return "authenticated as user", nil })
+
+		require.NoError(t, report.Err())
+		require.Len(t, report.Steps, 2)
+		require.Equal(t, HealthStepOk, report.Steps[0].Status)
+		require.Equal(t, "HTTP 200 OK", report.Steps[0].Message)
+		require.Equal(t, HealthStepOk, report.Steps[1].Status)
+	})
+
+	t.Run("steps after a failure are skipped", func(t *testing.T) {
+		errAuth := errors.New("invalid credentials")
+		ran := false
+
+		report := &HealthReport{}
+		report.Run("connectivity", func() (string, error) { return "HTTP 200 OK", nil })
+		report.Run("auth", func() (string, error) { return "", errAuth })
+		report.Run("whoami", func() (string, error) { ran = true; return "", nil })
+
+		require.ErrorIs(t, report.Err(), errAuth)
+		require.EqualError(t, report.Err(), "auth failed: invalid credentials")
+		require.False(t, ran)
+		require.Equal(t, []string{HealthStepOk, HealthStepError, HealthStepSkipped},
+			[]string{report.Steps[0].Status, report.Steps[1].Status, report.Steps[2].Status})
+		require.Equal(t, "invalid credentials", report.Steps[1].Message)
+	})
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// frameTypeHeatmapCells is the frame type of Grafana's heatmap with a row per cell.
+const frameTypeHeatmapCells data.FrameType = "heatmap-cells"
+
+// HeatmapOptions name the columns of a bucketed query result that heatmaps and
+// histograms are built from, e.g. summarize count() by bin(Timestamp, 1m), bin(Value, 10).
+type HeatmapOptions struct {
+	BucketColumn string `json:"bucketColumn"`
+	CountColumn  string `json:"countColumn"`
+	// BucketSize is the size passed to bin(). It defaults to the smallest distance
+	// between two buckets of the result.
+	BucketSize float64 `json:"bucketSize"`
+}
+
+// column names, in order of preference, used to detect the bucket columns
+var (
+	heatmapBucketColumns = []string{"bucket", "value", "le", "ymin"}
+	heatmapCountColumns  = []string{"count", "count_"}
+)
+
+// heatmapCell is a bucket of a heatmap, the time is zero for histograms.
+type heatmapCell struct {
+	time  time.Time
+	yMin  float64
+	count float64
+}
+
+// HeatmapFrame converts a query result with a row per bucket into the cells of a
+// heatmap, with the start time, yMin, yMax and count of every cell. Results without a
+// datetime column become a histogram with the xMin, xMax and count of every bucket.
+// Rows of the same cell are summed.
+func HeatmapFrame(frame *data.Frame, opts HeatmapOptions) (*data.Frame, error) {
+	if opts.BucketSize < 0 {
+		return nil, fmt.Errorf("invalid bucket size %v", opts.BucketSize)
+	}
+
+	finder := newColumnFinder(frame)
+	timeField := finder.first(isTimeField)
+	count, err := finder.find(opts.CountColumn, heatmapCountColumns, isNumericField)
+	if err != nil {
+		return nil, err
+	}
+	bucket, err := finder.find(opts.BucketColumn, heatmapBucketColumns, isNumericField)
+	if err != nil {
+		return nil, err
+	}
+	if bucket == nil {
+		bucket = finder.first(isNumericField)
+	}
+	if count == nil {
+		count = finder.first(isNumericField)
+	}
+	if bucket == nil || count == nil {
+		return nil, fmt.Errorf("heatmap format requires a numeric bucket and a numeric count column")
+	}
+
+	type cellKey struct {
+		time int64
+		yMin float64
+	}
+	cells := []heatmapCell{}
+	index := map[cellKey]int{}
+	for i := 0; i < frame.Rows(); i++ {
+		yMin, ok := floatAt(bucket, i)
+		if !ok {
+			continue
+		}
+		cell := heatmapCell{yMin: yMin}
+		if timeField != nil {
+			if cell.time, ok = timeAt(timeField, i); !ok {
+				continue
+			}
+		}
+		n, _ := floatAt(count, i)
+
+		key := cellKey{cell.time.UnixNano(), cell.yMin}
+		if j, ok := index[key]; ok {
+			cells[j].count += n
+			continue
+		}
+		index[key] = len(cells)
+		cell.count = n
+		cells = append(cells, cell)
+	}
+	sort.SliceStable(cells, func(i, j int) bool {
+		if !cells[i].time.Equal(cells[j].time) {
+			return cells[i].time.Before(cells[j].time)
+		}
+		return cells[i].yMin < cells[j].yMin
+	})
+
+	size := opts.BucketSize
+	if size == 0 {
+		size = bucketSize(cells)
+	}
+
+	times := make([]time.Time, len(cells))
+	yMins := make([]float64, len(cells))
+	yMaxs := make([]float64, len(cells))
+	counts := make([]float64, len(cells))
+	for i, c := range cells {
+		times[i] = c.time
+		yMins[i] = c.yMin
+		yMaxs[i] = c.yMin + size
+		counts[i] = c.count
+	}
+
+	// the bucket fields are displayed with the name of the bucket column
+	minField := data.NewField("yMin", nil, yMins).SetConfig(&data.FieldConfig{DisplayName: bucket.Name})
+	maxField := data.NewField("yMax", nil, yMaxs).SetConfig(&data.FieldConfig{DisplayName: bucket.Name})
+
+	var out *data.Frame
+	if timeField != nil {
+		out = data.NewFrame(frame.Name, data.NewField("xMin", nil, times), minField, maxField, data.NewField("count", nil, counts))
+		out.Meta = &data.FrameMeta{Type: frameTypeHeatmapCells}
+	} else {
+		minField.Name, maxField.Name = "xMin", "xMax"
+		out = data.NewFrame(frame.Name, minField, maxField, data.NewField("count", nil, counts))
+		out.Meta = &data.FrameMeta{}
+	}
+	if size == 0 && len(cells) > 0 {
+		out.AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     "The bucket size could not be detected from a single bucket, set the bucket size of the query.",
+		})
+	}
+	if frame.Meta != nil {
+		out.Meta.ExecutedQueryString = frame.Meta.ExecutedQueryString
+	}
+	return out, nil
+}
+
+// bucketSize returns the smallest distance between two buckets, or 0 when there
+// are fewer than two distinct buckets.
+func bucketSize(cells []heatmapCell) float64 {
+	buckets := make([]float64, 0, len(cells))
+	for _, c := range cells {
+		buckets = append(buckets, c.yMin)
+	}
+	sort.Float64s(buckets)
+
+	size := math.Inf(1)
+	for i := 1; i < len(buckets); i++ {
+		if d := buckets[i] - buckets[i-1]; d > 0 && d < size {
+			size = d
+		}
+	}
+	if math.IsInf(size, 1) {
+		return 0
+	}
+	return size
+}
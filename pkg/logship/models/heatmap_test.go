@@ -0,0 +1,100 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeatmapFrame(t *testing.T) {
+	t1 := time.Date(2023, 1, 1, 10, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Minute)
+
+	bucketField := func(name string, column string, values []float64) *data.Field {
+		return data.NewField(name, nil, values).SetConfig(&data.FieldConfig{DisplayName: column})
+	}
+
+	tests := []struct {
+		name    string
+		frame   *data.Frame
+		opts    HeatmapOptions
+		errorIs assert.ErrorAssertionFunc
+		want    *data.Frame
+	}{
+		{
+			name: "heatmap cells",
+			frame: data.NewFrame("",
+				nullableField("Timestamp", t2, t1, t1, t2, t2, nil),
+				nullableField("Latency", 0.0, 20.0, 0.0, 20.0, 20.0, 0.0),
+				nullableField("count_", int64(1), int64(2), int64(3), int64(4), int64(5), int64(6)),
+			),
+			errorIs: assert.NoError,
+			want: data.NewFrame("",
+				data.NewField("xMin", nil, []time.Time{t1, t1, t2, t2}),
+				bucketField("yMin", "Latency", []float64{0, 20, 0, 20}),
+				bucketField("yMax", "Latency", []float64{20, 40, 20, 40}),
+				data.NewField("count", nil, []float64{3, 2, 1, 9}),
+			).SetMeta(&data.FrameMeta{Type: frameTypeHeatmapCells}),
+		},
+		{
+			name: "histogram with a bucket size",
+			frame: data.NewFrame("",
+				data.NewField("Hits", nil, []int64{7, 3}),
+				data.NewField("Size", nil, []float64{100, 0}),
+			),
+			opts:    HeatmapOptions{BucketColumn: "Size", CountColumn: "Hits", BucketSize: 50},
+			errorIs: assert.NoError,
+			want: data.NewFrame("",
+				bucketField("xMin", "Size", []float64{0, 100}),
+				bucketField("xMax", "Size", []float64{50, 150}),
+				data.NewField("count", nil, []float64{3, 7}),
+			).SetMeta(&data.FrameMeta{}),
+		},
+		{
+			name: "single bucket",
+			frame: data.NewFrame("",
+				data.NewField("bucket", nil, []float64{10}),
+				data.NewField("count", nil, []int32{4}),
+			),
+			errorIs: assert.NoError,
+			want: data.NewFrame("",
+				bucketField("xMin", "bucket", []float64{10}),
+				bucketField("xMax", "bucket", []float64{10}),
+				data.NewField("count", nil, []float64{4}),
+			).SetMeta(&data.FrameMeta{Notices: []data.Notice{{
+				Severity: data.NoticeSeverityWarning,
+				Text:     "The bucket size could not be detected from a single bucket, set the bucket size of the query.",
+			}}}),
+		},
+		{
+			name:    "bucket column that is not numeric",
+			frame:   data.NewFrame("", data.NewField("Name", nil, []string{"a"}), data.NewField("count", nil, []int64{1})),
+			opts:    HeatmapOptions{BucketColumn: "Name"},
+			errorIs: assert.Error,
+		},
+		{
+			name:    "missing count column",
+			frame:   data.NewFrame("", data.NewField("bucket", nil, []float64{1})),
+			errorIs: assert.Error,
+		},
+		{
+			name:    "negative bucket size",
+			frame:   data.NewFrame("", data.NewField("bucket", nil, []float64{1}), data.NewField("count", nil, []int64{1})),
+			opts:    HeatmapOptions{BucketSize: -1},
+			errorIs: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := HeatmapFrame(tt.frame, tt.opts)
+			tt.errorIs(t, err)
+			if tt.want != nil {
+				require.Equal(t, tt.want, frame)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package models
+
+import (
+	"strings"
+)
+
+// kqlScan calls fn for every byte of query that is outside of string literals
+// and comments, along with the current bracket depth. Scanning stops when fn
+// returns false.
+func kqlScan(query string, fn func(i, depth int) bool) {
+	depth := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case strings.HasPrefix(query[i:], "//"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return
+			}
+			i += end
+			continue
+		case strings.HasPrefix(query[i:], "```"):
+			end := strings.Index(query[i+3:], "```")
+			if end < 0 {
+				return
+			}
+			i += end + 5
+			continue
+		case (c == '@' || c == 'h' || c == 'H') && i+1 < len(query) && (query[i+1] == '\'' || query[i+1] == '"') &&
+			(i == 0 || !isIdentByte(query[i-1])):
+			// verbatim (@"...") and obfuscated (h"...") string literals
+			end := kqlStringEnd(query, i+1, c == '@')
+			if end < 0 {
+				return
+			}
+			i = end
+			continue
+		case c == '\'' || c == '"':
+			end := kqlStringEnd(query, i, false)
+			if end < 0 {
+				return
+			}
+			i = end
+			continue
+		case c == '(' || c == '[' || c == '{':
+			if !fn(i, depth) {
+				return
+			}
+			depth++
+			continue
+		case c == ')' || c == ']' || c == '}':
+			if depth > 0 {
+				depth--
+			}
+		}
+		if !fn(i, depth) {
+			return
+		}
+	}
+}
+
+// kqlStringEnd returns the index of the closing quote of the string literal
+// that opens at start, or -1 when the literal is not terminated.
+func kqlStringEnd(query string, start int, verbatim bool) int {
+	quote := query[start]
+	for i := start + 1; i < len(query); i++ {
+		switch {
+		case query[i] == '\\' && !verbatim:
+			i++
+		case query[i] == quote:
+			if verbatim && i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// splitKQL splits query on any of the separator bytes found at bracket depth
+// zero and outside of string literals and comments.
+func splitKQL(query string, separators string) []string {
+	parts := []string{}
+	last := 0
+	kqlScan(query, func(i, depth int) bool {
+		if depth == 0 && strings.IndexByte(separators, query[i]) >= 0 {
+			parts = append(parts, query[last:i])
+			last = i + 1
+		}
+		return true
+	})
+	return append(parts, query[last:])
+}
+
+// kqlStages splits a query into its statements and tabular operators,
+// e.g. "T | where x | summarize count()" -> ["T", "where x", "summarize count()"].
+func kqlStages(query string) []string {
+	stages := []string{}
+	for _, s := range splitKQL(query, "|;") {
+		if s = strings.TrimSpace(s); s != "" {
+			stages = append(stages, s)
+		}
+	}
+	return stages
+}
+
+// kqlOperator returns the leading operator name of a stage and the remainder,
+// e.g. "make-series avg(x) on T step 1h" -> "make-series", "avg(x) on T step 1h".
+func kqlOperator(stage string) (string, string) {
+	i := 0
+	for i < len(stage) && (isIdentByte(stage[i]) || stage[i] == '-') {
+		i++
+	}
+	return stage[:i], stage[i:]
+}
+
+// kqlKeywordIndexes returns the start of every occurrence of the keyword in s
+// at bracket depth zero.
+func kqlKeywordIndexes(s string, keyword string) []int {
+	indexes := []int{}
+	kqlScan(s, func(i, depth int) bool {
+		if depth == 0 && strings.HasPrefix(s[i:], keyword) &&
+			(i == 0 || !isIdentByte(s[i-1])) &&
+			(i+len(keyword) == len(s) || !isIdentByte(s[i+len(keyword)])) {
+			indexes = append(indexes, i)
+		}
+		return true
+	})
+	return indexes
+}
+
+// kqlCall is a function call found in a query.
+type kqlCall struct {
+	Name  string
+	Args  []string
+	Start int
+	End   int
+}
+
+// kqlCalls finds all calls to the named functions in s, at any depth.
+func kqlCalls(s string, names ...string) []kqlCall {
+	calls := []kqlCall{}
+	kqlScan(s, func(i, depth int) bool {
+		if i > 0 && isIdentByte(s[i-1]) {
+			return true
+		}
+		for _, name := range names {
+			if !strings.HasPrefix(s[i:], name) {
+				continue
+			}
+			open := i + len(name)
+			for open < len(s) && (s[open] == ' ' || s[open] == '\t') {
+				open++
+			}
+			if open >= len(s) || s[open] != '(' {
+				continue
+			}
+			if call, ok := parseKQLCall(s, name, i, open); ok {
+				calls = append(calls, call)
+			}
+		}
+		return true
+	})
+	return calls
+}
+
+func parseKQLCall(s string, name string, start int, open int) (kqlCall, bool) {
+	end := -1
+	kqlScan(s[open:], func(i, depth int) bool {
+		if i > 0 && depth == 0 && s[open+i] == ')' {
+			end = open + i
+			return false
+		}
+		return true
+	})
+	if end < 0 {
+		return kqlCall{}, false
+	}
+
+	args := []string{}
+	if inner := s[open+1 : end]; strings.TrimSpace(inner) != "" {
+		for _, a := range splitKQL(inner, ",") {
+			args = append(args, strings.TrimSpace(a))
+		}
+	}
+	return kqlCall{Name: name, Args: args, Start: start, End: end + 1}, true
+}
@@ -15,6 +15,7 @@ import (
 //   - $__from ->  datetime(2018-06-05T18:09:58.907Z)
 //   - $__to -> datetime(2018-06-05T20:09:58.907Z)
 //   - $__interval -> 5m
+//   - $__fn(name, args...) -> the body of a saved query function, expanded before the other macros, see functions.go
 
 // MacroData contains the information needed for macro expansion.
 type MacroData struct {
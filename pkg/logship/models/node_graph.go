@@ -0,0 +1,154 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// NodeGraphOptions name the columns of an edge list that the node graph is built
+// from. Columns that are not named are detected from the column names.
+type NodeGraphOptions struct {
+	SourceColumn        string `json:"sourceColumn"`
+	TargetColumn        string `json:"targetColumn"`
+	MainStatColumn      string `json:"mainStatColumn"`
+	SecondaryStatColumn string `json:"secondaryStatColumn"`
+}
+
+// column names, in order of preference, used to detect the edge columns
+var (
+	nodeGraphSourceColumns        = []string{"source", "caller", "from", "client", "parent"}
+	nodeGraphTargetColumns        = []string{"target", "callee", "to", "server", "child"}
+	nodeGraphMainStatColumns      = []string{"mainstat", "count", "calls", "requests", "count_"}
+	nodeGraphSecondaryStatColumns = []string{"secondarystat", "latency", "duration", "avg_latency", "avg_duration"}
+)
+
+// nodeStats accumulates the stats of the edges going into a node.
+type nodeStats struct {
+	main      float64
+	hasMain   bool
+	secondary float64 // sum of the secondary stats
+	edges     int     // number of edges with a secondary stat
+}
+
+// NodeGraphFrames converts an edge list with a row per source and target into the
+// nodes and edges frames of Grafana's node graph. The stats of a node are the sum
+// of the main stat and the average of the secondary stat of its incoming edges.
+func NodeGraphFrames(frame *data.Frame, opts NodeGraphOptions) (data.Frames, error) {
+	finder := newColumnFinder(frame)
+	source, err := finder.find(opts.SourceColumn, nodeGraphSourceColumns, anyField)
+	if err != nil {
+		return nil, err
+	}
+	target, err := finder.find(opts.TargetColumn, nodeGraphTargetColumns, anyField)
+	if err != nil {
+		return nil, err
+	}
+	if source == nil || target == nil {
+		return nil, fmt.Errorf("node graph format requires a source and a target column")
+	}
+	mainStat, err := finder.find(opts.MainStatColumn, nodeGraphMainStatColumns, isNumericField)
+	if err != nil {
+		return nil, err
+	}
+	secondaryStat, err := finder.find(opts.SecondaryStatColumn, nodeGraphSecondaryStatColumns, isNumericField)
+	if err != nil {
+		return nil, err
+	}
+
+	edgeIDs := []string{}
+	sources := []string{}
+	targets := []string{}
+	edgeMain := []*float64{}
+	edgeSecondary := []*float64{}
+
+	nodeIDs := []string{}
+	nodes := map[string]*nodeStats{}
+	addNode := func(id string) *nodeStats {
+		if _, ok := nodes[id]; !ok {
+			nodeIDs = append(nodeIDs, id)
+			nodes[id] = &nodeStats{}
+		}
+		return nodes[id]
+	}
+
+	seen := map[string]int{}
+	for i := 0; i < frame.Rows(); i++ {
+		from, to := stringAt(source, i), stringAt(target, i)
+		if from == "" || to == "" {
+			continue
+		}
+		addNode(from)
+		node := addNode(to)
+
+		id := fmt.Sprintf("%s->%s", from, to)
+		if n := seen[id]; n > 0 {
+			seen[id]++
+			id = fmt.Sprintf("%s#%d", id, n)
+		} else {
+			seen[id] = 1
+		}
+		edgeIDs = append(edgeIDs, id)
+		sources = append(sources, from)
+		targets = append(targets, to)
+
+		var main, secondary *float64
+		if v, ok := floatAt(mainStat, i); ok {
+			main = &v
+			node.main += v
+			node.hasMain = true
+		}
+		if v, ok := floatAt(secondaryStat, i); ok {
+			secondary = &v
+			node.secondary += v
+			node.edges++
+		}
+		edgeMain = append(edgeMain, main)
+		edgeSecondary = append(edgeSecondary, secondary)
+	}
+
+	nodeMain := make([]*float64, len(nodeIDs))
+	nodeSecondary := make([]*float64, len(nodeIDs))
+	for i, id := range nodeIDs {
+		stats := nodes[id]
+		if stats.hasMain {
+			v := stats.main
+			nodeMain[i] = &v
+		}
+		if stats.edges > 0 {
+			v := stats.secondary / float64(stats.edges)
+			nodeSecondary[i] = &v
+		}
+	}
+
+	nodesFrame := data.NewFrame("nodes",
+		data.NewField("id", nil, nodeIDs),
+		data.NewField("title", nil, append([]string{}, nodeIDs...)),
+	)
+	edgesFrame := data.NewFrame("edges",
+		data.NewField("id", nil, edgeIDs),
+		data.NewField("source", nil, sources),
+		data.NewField("target", nil, targets),
+	)
+	if mainStat != nil {
+		nodesFrame.Fields = append(nodesFrame.Fields, statField("mainstat", mainStat.Name, nodeMain))
+		edgesFrame.Fields = append(edgesFrame.Fields, statField("mainstat", mainStat.Name, edgeMain))
+	}
+	if secondaryStat != nil {
+		nodesFrame.Fields = append(nodesFrame.Fields, statField("secondarystat", secondaryStat.Name, nodeSecondary))
+		edgesFrame.Fields = append(edgesFrame.Fields, statField("secondarystat", secondaryStat.Name, edgeSecondary))
+	}
+
+	for _, f := range []*data.Frame{nodesFrame, edgesFrame} {
+		f.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeNodeGraph}
+		if frame.Meta != nil {
+			f.Meta.ExecutedQueryString = frame.Meta.ExecutedQueryString
+		}
+	}
+	return data.Frames{nodesFrame, edgesFrame}, nil
+}
+
+// statField returns a node graph stat field displayed with the name of the column it comes from.
+func statField(name string, column string, values []*float64) *data.Field {
+	return data.NewField(name, nil, values).SetConfig(&data.FieldConfig{DisplayName: column})
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xorcare/pointer"
+)
+
+func TestNodeGraphFrames(t *testing.T) {
+	edges := func() *data.Frame {
+		return data.NewFrame("",
+			nullableField("Caller", "web", "web", "api", nil),
+			nullableField("Callee", "api", "db", "db", "db"),
+			nullableField("count_", int64(10), int64(2), int64(30), int64(1)),
+			nullableField("Latency", 5.0, 1.0, 3.0, nil),
+			nullableField("Errors", int64(1), nil, int64(0), int64(0)),
+		)
+	}
+	meta := &data.FrameMeta{PreferredVisualization: data.VisTypeNodeGraph}
+	stat := func(name string, column string, values ...*float64) *data.Field {
+		return data.NewField(name, nil, values).SetConfig(&data.FieldConfig{DisplayName: column})
+	}
+
+	tests := []struct {
+		name    string
+		opts    NodeGraphOptions
+		errorIs assert.ErrorAssertionFunc
+		frames  data.Frames
+	}{
+		{
+			name:    "detected columns",
+			errorIs: assert.NoError,
+			frames: data.Frames{
+				data.NewFrame("nodes",
+					data.NewField("id", nil, []string{"web", "api", "db"}),
+					data.NewField("title", nil, []string{"web", "api", "db"}),
+					stat("mainstat", "count_", nil, pointer.Float64(10), pointer.Float64(32)),
+					stat("secondarystat", "Latency", nil, pointer.Float64(5), pointer.Float64(2)),
+				).SetMeta(meta),
+				data.NewFrame("edges",
+					data.NewField("id", nil, []string{"web->api", "web->db", "api->db"}),
+					data.NewField("source", nil, []string{"web", "web", "api"}),
+					data.NewField("target", nil, []string{"api", "db", "db"}),
+					stat("mainstat", "count_", pointer.Float64(10), pointer.Float64(2), pointer.Float64(30)),
+					stat("secondarystat", "Latency", pointer.Float64(5), pointer.Float64(1), pointer.Float64(3)),
+				).SetMeta(meta),
+			},
+		},
+		{
+			name:    "overridden columns",
+			opts:    NodeGraphOptions{SourceColumn: "Callee", TargetColumn: "Caller", MainStatColumn: "Errors"},
+			errorIs: assert.NoError,
+			frames: data.Frames{
+				data.NewFrame("nodes",
+					data.NewField("id", nil, []string{"api", "web", "db"}),
+					data.NewField("title", nil, []string{"api", "web", "db"}),
+					stat("mainstat", "Errors", pointer.Float64(0), pointer.Float64(1), nil),
+					stat("secondarystat", "Latency", pointer.Float64(3), pointer.Float64(3), nil),
+				).SetMeta(meta),
+				data.NewFrame("edges",
+					data.NewField("id", nil, []string{"api->web", "db->web", "db->api"}),
+					data.NewField("source", nil, []string{"api", "db", "db"}),
+					data.NewField("target", nil, []string{"web", "web", "api"}),
+					stat("mainstat", "Errors", pointer.Float64(1), nil, pointer.Float64(0)),
+					stat("secondarystat", "Latency", pointer.Float64(5), pointer.Float64(1), pointer.Float64(3)),
+				).SetMeta(meta),
+			},
+		},
+		{
+			name:    "stat column that is not numeric",
+			opts:    NodeGraphOptions{MainStatColumn: "Caller"},
+			errorIs: assert.Error,
+		},
+		{
+			name:    "missing column",
+			opts:    NodeGraphOptions{SourceColumn: "Missing"},
+			errorIs: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames, err := NodeGraphFrames(edges(), tt.opts)
+			tt.errorIs(t, err)
+			if tt.frames != nil {
+				require.Equal(t, tt.frames, frames)
+			}
+		})
+	}
+}
@@ -1,13 +1,47 @@
 package models
 
+import "github.com/grafana/grafana-plugin-sdk-go/backend"
+
+const (
+	// QueryTypeRaw runs the KQL query as written.
+	QueryTypeRaw = "raw"
+	// QueryTypeFunction calls the stored Function with the Parameters.
+	QueryTypeFunction = "function"
+	// QueryTypeVariable returns the template variable values of the query, see VariableValues.
+	QueryTypeVariable = "variable"
+	// QueryTypeAnnotations returns the annotations of the query, see AnnotationFrame.
+	QueryTypeAnnotations = "annotations"
+)
+
 // QueryModel contains the query information from the API call that we use to make a query.
 type QueryModel struct {
-	Format      string `json:"resultFormat"`
-	QueryType   string `json:"queryType"`
-	Query       string `json:"query"`
-	Database    string `json:"database"`
-	QuerySource string `json:"querySource"` // used to identify if query came from getSchema, raw mode, etc
+	Format      string              `json:"resultFormat"`
+	QueryType   string              `json:"queryType"`
+	Query       string              `json:"query"`
+	Database    string              `json:"database"`
+	QuerySource string              `json:"querySource"` // used to identify if query came from getSchema, raw mode, etc
+	TimeShift   string              `json:"timeShift"`   // optional KQL timespan the time range is moved by, e.g. -7d
+	Function    string              `json:"function"`    // stored function called by QueryTypeFunction queries
+	Parameters  []FunctionParameter `json:"parameters"`  // arguments of the stored function, in order
 	MacroData   MacroData
+
+	// FrameOptions apply to the conversion of the results of every format.
+	FrameOptions
+
+	// VariableOptions apply to QueryTypeVariable queries.
+	VariableOptions
+
+	// AnnotationOptions apply to QueryTypeAnnotations queries.
+	AnnotationOptions
+
+	// TraceOptions apply to queries with the trace format.
+	TraceOptions
+
+	// NodeGraphOptions apply to queries with the node_graph format.
+	NodeGraphOptions
+
+	// HeatmapOptions apply to queries with the heatmap format.
+	HeatmapOptions
 }
 
 // Interpolate applies macro expansion on the QueryModel's Payload's Query string
@@ -15,3 +49,16 @@ func (qm *QueryModel) Interpolate() (err error) {
 	qm.Query, err = qm.MacroData.Interpolate(qm.Query)
 	return
 }
+
+// ExpandFunctions replaces the $__fn calls of the query with the bodies of the saved
+// functions, so that the macros of the bodies are expanded like those of the query.
+func (qm *QueryModel) ExpandFunctions(fns []QueryFunction) (err error) {
+	qm.Query, err = ExpandFunctions(qm.Query, fns)
+	return
+}
+
+// InterpolateResults expands $__result macros using the responses of the queries it depends on.
+func (qm *QueryModel) InterpolateResults(results backend.Responses) (err error) {
+	qm.Query, err = InterpolateResults(qm.Query, results)
+	return
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"fmt"
+)
+
+// QueryError is a non successful response returned by Logship.
+type QueryError struct {
+	StatusCode int
+	Status     string
+	Message    string
+	Errors     []TokenizedErrorMessage
+	StackTrace string
+}
+
+// NewQueryError creates a QueryError from the status and error body of a Logship response.
+func NewQueryError(statusCode int, status string, body *ErrorResponse) *QueryError {
+	qe := &QueryError{
+		StatusCode: statusCode,
+		Status:     status,
+	}
+	if body != nil {
+		qe.Message = body.Message
+		qe.Errors = body.Errors
+		qe.StackTrace = body.StackTrace
+	}
+	return qe
+}
+
+// Error returns the status and message of the response, the stack trace is left out.
+func (e *QueryError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("HTTP %q", e.Status)
+	}
+	return fmt.Sprintf("HTTP %q with error message: %q", e.Status, e.Message)
+}
+
+// ErrorResponse returns the error body the QueryError was created from.
+func (e *QueryError) ErrorResponse() *ErrorResponse {
+	return &ErrorResponse{
+		Message:    e.Message,
+		StackTrace: e.StackTrace,
+		Errors:     e.Errors,
+	}
+}
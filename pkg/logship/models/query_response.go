@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"math/big"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/data"
@@ -30,10 +32,42 @@ type TableResponse struct {
 // 	return Tabl, fmt.Errorf("no data as %v table is missing from the the response", name)
 // }
 
-func (tr *TableResponse) ToDataFrames(executedQueryString string) (data.Frames, error) {
+// FrameOptions control how a table response is converted to data frames.
+type FrameOptions struct {
+	// GeoMode is one of the GeoMode constants, it defaults to GeoModeNone.
+	GeoMode string `json:"geoMode"`
+	// GeohashPrecision is the number of characters of the geohashes of GeoModeGeohash, 12 by default.
+	GeohashPrecision int `json:"geohashPrecision"`
+	// FlattenDepth replaces Dynamic columns of objects by a typed field per key, with
+	// the keys of nested objects flattened up to that many levels. 0 disables flattening.
+	FlattenDepth int `json:"flattenDepth"`
+	// Int128AsString converts Int128 columns to strings of every digit instead of
+	// float64 numbers, which lose precision above 2^53. It is a datasource setting.
+	Int128AsString bool `json:"-"`
+	// TypeMappings are the column type mappings of the datasource settings.
+	TypeMappings ColumnTypeMappings `json:"-"`
+}
+
+// Validate returns an error for options that are out of range.
+func (opts FrameOptions) Validate() error {
+	if opts.FlattenDepth < 0 {
+		return fmt.Errorf("invalid flatten depth %d", opts.FlattenDepth)
+	}
+	switch opts.GeoMode {
+	case "", GeoModeNone, GeoModeCoordinates, GeoModeGeohash:
+	default:
+		return fmt.Errorf("invalid geo mode %q", opts.GeoMode)
+	}
+	if opts.GeohashPrecision < 0 || opts.GeohashPrecision > defaultGeohashPrecision {
+		return fmt.Errorf("invalid geohash precision %d, it must be between 1 and %d", opts.GeohashPrecision, defaultGeohashPrecision)
+	}
+	return nil
+}
+
+func (tr *TableResponse) ToDataFrames(executedQueryString string, opts FrameOptions) (data.Frames, error) {
 	// table, err := tr.getTableByName("Table_0")
 
-	converterFrame, err := converterFrameForTable(*tr, executedQueryString)
+	converterFrame, err := converterFrameForTable(*tr, executedQueryString, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -41,16 +75,29 @@ func (tr *TableResponse) ToDataFrames(executedQueryString string) (data.Frames,
 	return data.Frames{converterFrame.Frame}, nil
 }
 
-func converterFrameForTable(t TableResponse, executedQueryString string) (*data.FrameInputConverter, error) {
+func converterFrameForTable(t TableResponse, executedQueryString string, opts FrameOptions) (*data.FrameInputConverter, error) {
 	converters := make([]data.FieldConverter, len(t.Columns))
 	colNames := make([]string, len(t.Columns))
 	colTypes := make([]string, len(t.Columns))
+	// convertTypes are the types the columns are converted as
+	convertTypes := make([]string, len(t.Columns))
+	notices := []data.Notice{}
 	for i, col := range t.Columns {
 		colNames[i] = col.Name
 		colTypes[i] = col.Type
-		converter, ok := converterMap[col.Type]
+		typ, ok := opts.TypeMappings.resolve(col.Type)
 		if !ok {
-			return nil, fmt.Errorf("unsupported analytics column type %v", col.Type)
+			notices = append(notices, data.Notice{
+				Severity: data.NoticeSeverityWarning,
+				Text:     fmt.Sprintf("Column %q has the unsupported type %q and is returned as a string. Map the type to a supported type in the datasource settings to convert it.", col.Name, col.Type),
+			})
+			converters[i] = fallbackConverter
+			continue
+		}
+		convertTypes[i] = typ
+		converter := converterMap[typ]
+		if typ == "Int128" && opts.Int128AsString {
+			converter = int128StringConverter
 		}
 		converters[i] = converter
 	}
@@ -64,11 +111,17 @@ func converterFrameForTable(t TableResponse, executedQueryString string) (*data.
 	if err != nil {
 		return nil, err
 	}
+	for i, typ := range convertTypes {
+		fic.Frame.Fields[i].SetConfig(columnConfig(typ, colNames[i], t.Results))
+	}
 
 	fic.Frame.Meta = &data.FrameMeta{
 		ExecutedQueryString: executedQueryString,
 		Custom:              LogshipFrameMD{ColumnTypes: colTypes},
 	}
+	if len(notices) > 0 {
+		fic.Frame.AppendNotices(notices...)
+	}
 
 	for row_index, row := range t.Results {
 		for f_index, fname := range colNames {
@@ -76,19 +129,81 @@ func converterFrameForTable(t TableResponse, executedQueryString string) (*data.
 		}
 	}
 
+	expandDynamicColumns(fic.Frame, t, convertTypes, opts)
+	applyFieldConfig(fic.Frame, executedQueryString)
+
 	return fic, nil
 }
 
+// expandDynamicColumns flattens the Dynamic columns of objects and adds the geo
+// fields of the GeoJSON columns after them, keeping the column types of the frame
+// metadata in line with its fields. convertTypes are the types the columns are converted as.
+// The options are validated with the request, unknown geo modes add no fields.
+func expandDynamicColumns(frame *data.Frame, t TableResponse, convertTypes []string, opts FrameOptions) {
+	if (opts.GeoMode == "" || opts.GeoMode == GeoModeNone) && opts.FlattenDepth == 0 {
+		return
+	}
+
+	// geo fields are prefixed with their column when several columns may add them
+	// or when a column already has their name
+	dynamic := 0
+	for _, typ := range convertTypes {
+		if typ == "Dynamic" {
+			dynamic++
+		}
+	}
+	prefix := dynamic > 1
+	for _, col := range t.Columns {
+		prefix = prefix || contains(geoFieldNames(opts.GeoMode), col.Name)
+	}
+
+	md := frame.Meta.Custom.(LogshipFrameMD)
+	fields := []*data.Field{}
+	types := []string{}
+	for i, col := range t.Columns {
+		if convertTypes[i] != "Dynamic" {
+			fields = append(fields, frame.Fields[i])
+			types = append(types, col.Type)
+			continue
+		}
+
+		values := make([]interface{}, len(t.Results))
+		for row, r := range t.Results {
+			values[row] = r[col.Name]
+		}
+
+		flat, flatTypes := []*data.Field(nil), []string(nil)
+		if opts.FlattenDepth > 0 {
+			flat, flatTypes = flattenFields(col.Name, values, opts.FlattenDepth)
+		}
+		if flat != nil {
+			fields = append(fields, flat...)
+			types = append(types, flatTypes...)
+		} else {
+			fields = append(fields, frame.Fields[i])
+			types = append(types, col.Type)
+		}
+
+		geo, geoTypes := geoFields(col.Name, values, opts, prefix)
+		fields = append(fields, geo...)
+		types = append(types, geoTypes...)
+	}
+
+	frame.Fields = fields
+	md.ColumnTypes = types
+	frame.Meta.Custom = md
+}
+
 var converterMap = map[string]data.FieldConverter{
 	"String":   stringConverter,
 	"Guid":     stringConverter,
-	"TimeSpan": stringConverter,
+	"TimeSpan": timespanConverter,
 	"Dynamic":  dynamicConverter,
 	"DateTime": timeConverter,
 	"Int32":    intConverter,
 	"UInt32":   intConverter,
 	"Int64":    longConverter,
-	"Int128":	stringConverter,
+	"Int128":   int128Converter,
 	"UInt64":   longConverter,
 	"real":     realConverter,
 	"Float32":  realConverter,
@@ -108,6 +223,85 @@ var dynamicConverter = data.FieldConverter{
 	},
 }
 
+// timespanConverter converts [-][d.]hh:mm:ss[.fffffff] timespans into milliseconds.
+var timespanConverter = data.FieldConverter{
+	OutputFieldType: data.FieldTypeNullableFloat64,
+	Converter: func(v interface{}) (interface{}, error) {
+		var af *float64
+		if v == nil {
+			return af, nil
+		}
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected type, expected string but got type %T with a value of %v", v, v)
+		}
+		ms, err := ClockTimespanMilliseconds(s)
+		if err != nil {
+			return nil, err
+		}
+		return &ms, nil
+	},
+}
+
+var int128Converter = data.FieldConverter{
+	OutputFieldType: data.FieldTypeNullableFloat64,
+	Converter: func(v interface{}) (interface{}, error) {
+		var af *float64
+		if v == nil {
+			return af, nil
+		}
+		i, err := parseInt128(v)
+		if err != nil {
+			return nil, err
+		}
+		f, _ := new(big.Float).SetInt(i).Float64()
+		return &f, nil
+	},
+}
+
+var int128StringConverter = data.FieldConverter{
+	OutputFieldType: data.FieldTypeNullableString,
+	Converter: func(v interface{}) (interface{}, error) {
+		var as *string
+		if v == nil {
+			return as, nil
+		}
+		i, err := parseInt128(v)
+		if err != nil {
+			return nil, err
+		}
+		s := i.String()
+		return &s, nil
+	},
+}
+
+var (
+	minInt128 = new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 127))
+	maxInt128 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 127), big.NewInt(1))
+)
+
+// parseInt128 parses an Int128 value, which is a JSON number or a string of digits.
+func parseInt128(v interface{}) (*big.Int, error) {
+	var s string
+	switch n := v.(type) {
+	case json.Number:
+		s = n.String()
+	case string:
+		s = strings.TrimSpace(n)
+	default:
+		return nil, fmt.Errorf("unexpected type, expected json.Number or string but got type %T with a value of %v", v, v)
+	}
+
+	i, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid Int128 value %q", s)
+	}
+	if i.Cmp(minInt128) < 0 || i.Cmp(maxInt128) > 0 {
+		return nil, fmt.Errorf("value %q is out of range for Int128", s)
+	}
+	return i, nil
+}
+
 var stringConverter = data.FieldConverter{
 	OutputFieldType: data.FieldTypeNullableString,
 	Converter: func(v interface{}) (interface{}, error) {
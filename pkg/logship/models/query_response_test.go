@@ -0,0 +1,114 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xorcare/pointer"
+)
+
+func TestTimespanConverter(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		errorIs assert.ErrorAssertionFunc
+		want    *float64
+	}{
+		{name: "null", value: nil, errorIs: assert.NoError},
+		{name: "zero", value: "00:00:00", errorIs: assert.NoError, want: pointer.Float64(0)},
+		{name: "fraction", value: "00:00:01.5", errorIs: assert.NoError, want: pointer.Float64(1500)},
+		{name: "tick", value: "00:00:00.0000001", errorIs: assert.NoError, want: pointer.Float64(0.0001)},
+		{name: "days", value: "1.02:03:04", errorIs: assert.NoError, want: pointer.Float64(93784000)},
+		{name: "negative", value: "-00:01:00", errorIs: assert.NoError, want: pointer.Float64(-60000)},
+		{name: "TimeSpan.MaxValue", value: "10675199.02:48:05.4775807", errorIs: assert.NoError, want: pointer.Float64(922337203685477.5807)},
+		{name: "TimeSpan.MinValue", value: "-10675199.02:48:05.4775808", errorIs: assert.NoError, want: pointer.Float64(-922337203685477.5808)},
+		{name: "literal", value: "1h", errorIs: assert.Error},
+		{name: "number", value: json.Number("10"), errorIs: assert.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := timespanConverter.Converter(tt.value)
+			tt.errorIs(t, err)
+			if err == nil {
+				assert.Equal(t, tt.want, v)
+			}
+		})
+	}
+}
+
+func TestInt128Converters(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		errorIs assert.ErrorAssertionFunc
+		number  *float64
+		str     *string
+	}{
+		{name: "null", value: nil, errorIs: assert.NoError},
+		{name: "number", value: json.Number("42"), errorIs: assert.NoError, number: pointer.Float64(42), str: pointer.String("42")},
+		{name: "negative string", value: "-7", errorIs: assert.NoError, number: pointer.Float64(-7), str: pointer.String("-7")},
+		{
+			name:    "beyond float64 precision",
+			value:   json.Number("9007199254740993"),
+			errorIs: assert.NoError,
+			number:  pointer.Float64(9007199254740992),
+			str:     pointer.String("9007199254740993"),
+		},
+		{
+			name:    "largest",
+			value:   "170141183460469231731687303715884105727",
+			errorIs: assert.NoError,
+			number:  pointer.Float64(1.7014118346046923e38),
+			str:     pointer.String("170141183460469231731687303715884105727"),
+		},
+		{
+			name:    "smallest",
+			value:   "-170141183460469231731687303715884105728",
+			errorIs: assert.NoError,
+			number:  pointer.Float64(-1.7014118346046923e38),
+			str:     pointer.String("-170141183460469231731687303715884105728"),
+		},
+		{name: "too large", value: "170141183460469231731687303715884105728", errorIs: assert.Error},
+		{name: "too small", value: "-170141183460469231731687303715884105729", errorIs: assert.Error},
+		{name: "fraction", value: json.Number("1.5"), errorIs: assert.Error},
+		{name: "not a number", value: "abc", errorIs: assert.Error},
+		{name: "bool", value: true, errorIs: assert.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			number, err := int128Converter.Converter(tt.value)
+			tt.errorIs(t, err)
+			str, strErr := int128StringConverter.Converter(tt.value)
+			tt.errorIs(t, strErr)
+			if err == nil {
+				assert.Equal(t, tt.number, number)
+				assert.Equal(t, tt.str, str)
+			}
+		})
+	}
+}
+
+func TestTimespanAndInt128Fields(t *testing.T) {
+	tr, err := TableFromJSON(strings.NewReader(`{
+		"Columns": [{"Name": "Elapsed", "Type": "TimeSpan"}, {"Name": "Id", "Type": "Int128"}],
+		"Results": [{"Elapsed": "00:00:02", "Id": 12345678901234567890}, {"Elapsed": null, "Id": null}]
+	}`))
+	require.NoError(t, err)
+
+	frames, err := tr.ToDataFrames("", FrameOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []*data.Field{
+		withLogshipType("TimeSpan", data.NewField("Elapsed", nil, []*float64{pointer.Float64(2000), nil}).SetConfig(&data.FieldConfig{Unit: "ms"})),
+		withLogshipType("Int128", data.NewField("Id", nil, []*float64{pointer.Float64(12345678901234567890), nil})),
+	}, frames[0].Fields)
+
+	frames, err = tr.ToDataFrames("", FrameOptions{Int128AsString: true})
+	require.NoError(t, err)
+	require.Equal(t, withLogshipType("Int128", data.NewField("Id", nil, []*string{pointer.String("12345678901234567890"), nil})), frames[0].Fields[1])
+}
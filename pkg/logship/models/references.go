@@ -0,0 +1,434 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+//  Query references:
+//   - Tables, materialized views and parameterless functions are referenced by the
+//     names in tabular positions: the start of a pipeline or a subquery, the operands
+//     of union, join and lookup, the tables of find and search, and the names passed
+//     to table(), materialized_view() and database("name").
+//   - Stored functions are referenced by calls anywhere in the query, and arguments
+//     are tabular positions when the parameter of the called function is tabular.
+//   - Names in any other position are columns or scalars, even when a table has the
+//     same name.
+
+// kqlReference is a name referenced in a tabular position or a call of a query.
+type kqlReference struct {
+	// Database is set when the name is qualified with database("name").
+	Database string
+	Name     string
+	// Call is set for names called with arguments, which can only be functions.
+	Call bool
+	// Function is set for names passed as an argument to a function, in the database
+	// FunctionDatabase. They are references when the parameter Index is tabular.
+	Function         string
+	FunctionDatabase string
+	Index            int
+}
+
+// kqlReferences are the references of a query along with the names it binds itself.
+type kqlReferences struct {
+	references []kqlReference
+	// bound are the names bound by let statements, function parameters and as.
+	bound map[string]bool
+	// tabular are the indexes of the tabular parameters of the functions bound by let.
+	tabular map[string]map[int]bool
+}
+
+// operatorPipelines are the operators whose subqueries start with an operator,
+// applied to the input of the operator, instead of a table.
+var operatorPipelines = map[string]bool{
+	"fork":      true,
+	"facet":     true,
+	"partition": true,
+	"mv-apply":  true,
+}
+
+// sourceOperators are the operators that start a pipeline without an input.
+var sourceOperators = map[string]bool{
+	"print":        true,
+	"range":        true,
+	"datatable":    true,
+	"externaldata": true,
+	"evaluate":     true,
+}
+
+// scalarKeywords are names in tabular positions of let statements that are values.
+var scalarKeywords = map[string]bool{
+	"true":  true,
+	"false": true,
+}
+
+// tabularArguments are the operators and functions that accept a single tabular
+// expression in parentheses, e.g. x in (T) or toscalar(T).
+var tabularArguments = map[string]bool{
+	"in":          true,
+	"!in":         true,
+	"in~":         true,
+	"!in~":        true,
+	"has_any":     true,
+	"has_all":     true,
+	"toscalar":    true,
+	"materialize": true,
+}
+
+// referenceCalls are the functions that reference tables or databases by name.
+var referenceCalls = []string{"cluster", "database", "table", "materialized_view", "external_table"}
+
+var operatorOptionRE = regexp.MustCompile(`^\s*(?i:kind|withsource|with_source|isfuzzy|hint\.\w+)\s*=\s*\S+`)
+
+// referenceParser collects the references of a query.
+type referenceParser struct {
+	kqlReferences
+	// resolved counts the calls of referenceCalls that were resolved to a name.
+	resolved int
+	err      error
+}
+
+// queryReferences returns the references of the query. It returns an error for
+// references that cannot be resolved statically, such as computed table names,
+// other clusters or searches across every table.
+func queryReferences(query string) (kqlReferences, error) {
+	p := &referenceParser{kqlReferences: kqlReferences{bound: map[string]bool{}, tabular: map[string]map[int]bool{}}}
+	p.statements(query)
+	if p.err != nil {
+		return kqlReferences{}, p.err
+	}
+	// calls of referenceCalls in any other position are not understood
+	if len(kqlCalls(query, referenceCalls...)) > p.resolved {
+		return kqlReferences{}, fmt.Errorf("table(), database() or cluster() references in this position cannot be resolved")
+	}
+
+	for _, name := range kqlCallNames(query) {
+		p.references = append(p.references, kqlReference{Name: name, Call: true})
+	}
+	return p.kqlReferences, nil
+}
+
+func (p *referenceParser) fail(format string, args ...interface{}) {
+	if p.err == nil {
+		p.err = fmt.Errorf(format, args...)
+	}
+}
+
+// statements records the references of the statements of a query or function body.
+func (p *referenceParser) statements(query string) {
+	for _, statement := range splitKQL(query, ";") {
+		statement = strings.TrimSpace(statement)
+		keyword, rest := kqlOperator(statement)
+		switch keyword {
+		case "let":
+			p.let(rest)
+		case "set", "declare", "restrict":
+			// options, query parameters and access restrictions reference nothing new
+		case "alias", "pattern":
+			p.fail("%s statements cannot be resolved", keyword)
+		default:
+			if statement != "" {
+				p.pipeline(statement, true)
+			}
+		}
+	}
+}
+
+// let records the references of a let statement, which binds a name to a value, a
+// tabular expression, a function or a view.
+func (p *referenceParser) let(s string) {
+	name, value, _ := strings.Cut(s, "=")
+	p.bound[unquoteIdentifier(strings.TrimSpace(name))] = true
+
+	value = strings.TrimSpace(value)
+	if keyword, rest := kqlOperator(value); keyword == "view" {
+		value = strings.TrimSpace(rest)
+	}
+	if strings.HasPrefix(value, "(") {
+		end := kqlGroupEnd(value, 0)
+		body := ""
+		if end > 0 {
+			body = strings.TrimSpace(value[end+1:])
+		}
+		if strings.HasPrefix(body, "{") {
+			function := unquoteIdentifier(strings.TrimSpace(name))
+			p.tabular[function] = map[int]bool{}
+			for i, param := range parseFunctionParameters(value[:end+1]) {
+				p.bound[param.Name] = true
+				p.tabular[function][i] = param.Type == "table"
+			}
+			if bodyEnd := kqlGroupEnd(body, 0); bodyEnd > 0 {
+				p.statements(body[1:bodyEnd])
+			}
+			return
+		}
+	}
+	p.pipeline(value, true)
+}
+
+// pipeline records the references of a tabular expression. Pipelines that are not
+// sourced start with an operator that applies to the input of a subquery.
+func (p *referenceParser) pipeline(s string, sourced bool) {
+	for i, stage := range kqlStages(s) {
+		operator, rest := kqlOperator(stage)
+		switch {
+		case operator == "union":
+			for _, operand := range splitKQL(stripOperatorOptions(rest), ",") {
+				p.source(operand, "")
+			}
+		case operator == "join" || operator == "lookup":
+			right := stripOperatorOptions(rest)
+			if on := kqlKeywordIndexes(right, "on"); len(on) > 0 {
+				p.nested(right[on[0]:], false)
+				right = right[:on[0]]
+			}
+			p.source(right, "")
+		case operator == "find" || operator == "search":
+			p.search(operator, rest, i == 0 && sourced)
+		case operator == "as":
+			p.bound[unquoteIdentifier(strings.TrimSpace(stripOperatorOptions(rest)))] = true
+		case i == 0 && sourced && !sourceOperators[operator]:
+			p.source(stage, "")
+		default:
+			p.nested(stage, operatorPipelines[operator])
+		}
+	}
+}
+
+// search records the tables of a find or search operator. Without a list of tables
+// they search every table when they start a pipeline.
+func (p *referenceParser) search(operator string, rest string, first bool) {
+	rest = strings.TrimSpace(stripOperatorOptions(rest))
+	if keyword, tables := kqlOperator(rest); keyword == "in" {
+		tables = strings.TrimSpace(tables)
+		if end := kqlGroupEnd(tables, 0); strings.HasPrefix(tables, "(") && end > 0 {
+			for _, table := range splitKQL(tables[1:end], ",") {
+				p.source(table, "")
+			}
+			p.nested(tables[end+1:], false)
+			return
+		}
+	}
+	if first {
+		p.fail("%s across every table cannot be resolved, name the tables with %s in (...)", operator, operator)
+		return
+	}
+	p.nested(rest, false)
+}
+
+// source records the table, materialized view or function a tabular expression
+// starts with, in the database when it is qualified with database().
+func (p *referenceParser) source(s string, database string) {
+	s = strings.TrimSpace(s)
+	name, rest := kqlLeadingName(s)
+	if name == "" {
+		if strings.HasPrefix(s, "(") {
+			if end := kqlGroupEnd(s, 0); end > 0 {
+				p.pipeline(s[1:end], true)
+				p.nested(s[end+1:], false)
+				return
+			}
+		}
+		p.nested(s, false)
+		return
+	}
+
+	call, isCall := kqlCallAt(s, name)
+	switch {
+	case isCall && name == "cluster":
+		p.fail("cluster() references cannot be resolved")
+	case isCall && name == "database":
+		qualified := strings.TrimSpace(s[call.End:])
+		literal, ok := kqlStringArgument(call.Args)
+		if !ok || database != "" || strings.Contains(literal, "*") || !strings.HasPrefix(qualified, ".") {
+			p.fail("database references other than database(\"name\").name cannot be resolved")
+			return
+		}
+		p.resolved++
+		p.source(qualified[1:], literal)
+	case isCall && (name == "table" || name == "materialized_view" || name == "external_table"):
+		literal, ok := kqlStringArgument(call.Args)
+		if !ok {
+			p.fail("%s() references with a computed name cannot be resolved", name)
+			return
+		}
+		p.resolved++
+		p.references = append(p.references, kqlReference{Database: database, Name: literal})
+		p.nested(s[call.End:], false)
+	case isCall:
+		p.references = append(p.references, kqlReference{Database: database, Name: name, Call: true})
+		p.arguments(name, database, s[strings.IndexByte(s, '(')+1:call.End-1])
+		p.nested(s[call.End:], false)
+	case scalarKeywords[name] && database == "":
+		p.nested(rest, false)
+	default:
+		p.references = append(p.references, kqlReference{Database: database, Name: name})
+		p.nested(rest, false)
+	}
+}
+
+// nested records the references of the subqueries in the brackets of s. Subqueries
+// are the bracketed pipelines, the tabular arguments of tabularArguments and the
+// arguments of calls.
+func (p *referenceParser) nested(s string, operands bool) {
+	kqlScan(s, func(i, depth int) bool {
+		if depth != 0 || strings.IndexByte("([{", s[i]) < 0 {
+			return true
+		}
+		end := kqlGroupEnd(s, i)
+		if end < 0 {
+			return false
+		}
+
+		content := s[i+1 : end]
+		switch {
+		case s[i] == '[' && strings.TrimSpace(content) != "" && strings.IndexByte(`'"`, strings.TrimSpace(content)[0]) >= 0:
+			// quoted identifier
+		case len(splitKQL(content, "|")) > 1:
+			p.pipeline(content, !operands)
+		case s[i] == '(' && tabularArguments[precedingWord(s, i)] && len(splitKQL(content, ",")) == 1:
+			p.pipeline(content, true)
+		case s[i] == '(' && isCallName(precedingWord(s, i)):
+			p.arguments(precedingWord(s, i), "", content)
+		default:
+			p.nested(content, operands)
+		}
+		p.nested(s[end+1:], operands)
+		return false
+	})
+}
+
+// arguments records the references of the arguments of a call of the function.
+// Names passed as arguments reference tables when the parameter is tabular, which
+// is only known once the function is resolved.
+func (p *referenceParser) arguments(function string, database string, args string) {
+	for i, arg := range splitKQL(args, ",") {
+		arg = strings.TrimSpace(arg)
+		name, rest := kqlLeadingName(arg)
+		_, isCall := kqlCallAt(arg, name)
+		switch {
+		case len(splitKQL(arg, "|")) > 1:
+			p.pipeline(arg, true)
+		case isCall && contains(referenceCalls, name):
+			p.source(arg, "")
+		case name != "" && !isCall && strings.TrimSpace(rest) == "":
+			p.references = append(p.references, kqlReference{Name: name, Function: function, FunctionDatabase: database, Index: i})
+		default:
+			p.nested(arg, false)
+		}
+	}
+}
+
+// stripOperatorOptions removes the name=value options that precede the operands
+// of an operator, e.g. kind=inner or hint.strategy=shuffle.
+func stripOperatorOptions(s string) string {
+	for {
+		loc := operatorOptionRE.FindStringIndex(s)
+		if loc == nil {
+			return s
+		}
+		s = s[loc[1]:]
+	}
+}
+
+// kqlLeadingName returns the identifier or quoted identifier that s starts with,
+// including * wildcards, and the rest of s.
+func kqlLeadingName(s string) (string, string) {
+	if strings.HasPrefix(s, "[") && len(s) > 1 && (s[1] == '\'' || s[1] == '"') {
+		if end := kqlStringEnd(s, 1, false); end > 0 && end+1 < len(s) && s[end+1] == ']' {
+			return unquoteIdentifier(s[:end+2]), s[end+2:]
+		}
+		return "", s
+	}
+	end := 0
+	for end < len(s) && (isIdentByte(s[end]) || s[end] == '*') {
+		end++
+	}
+	if end == 0 || s[0] >= '0' && s[0] <= '9' {
+		return "", s
+	}
+	return s[:end], s[end:]
+}
+
+// kqlCallAt returns the call of the name that s starts with, if any.
+func kqlCallAt(s string, name string) (kqlCall, bool) {
+	open := len(name)
+	for open < len(s) && (s[open] == ' ' || s[open] == '\t') {
+		open++
+	}
+	if open >= len(s) || s[open] != '(' {
+		return kqlCall{}, false
+	}
+	return parseKQLCall(s, name, 0, open)
+}
+
+// kqlStringArgument returns the value of a first argument that is a string literal.
+func kqlStringArgument(args []string) (string, bool) {
+	if len(args) == 0 || len(args[0]) < 2 || strings.IndexByte(`'"`, args[0][0]) < 0 {
+		return "", false
+	}
+	if kqlStringEnd(args[0], 0, false) != len(args[0])-1 || strings.Contains(args[0], `\`) {
+		return "", false
+	}
+	return args[0][1 : len(args[0])-1], true
+}
+
+// kqlGroupEnd returns the index of the bracket closing the one at open, or -1.
+func kqlGroupEnd(s string, open int) int {
+	end := -1
+	kqlScan(s[open:], func(i, depth int) bool {
+		if i > 0 && depth == 0 && strings.IndexByte(")]}", s[open+i]) >= 0 {
+			end = open + i
+			return false
+		}
+		return true
+	})
+	return end
+}
+
+// kqlCallNames returns the names of the functions called in the query, leaving out
+// calls qualified with a database, which are resolved as tabular references.
+func kqlCallNames(query string) []string {
+	names := []string{}
+	kqlScan(query, func(i, depth int) bool {
+		if !isIdentByte(query[i]) || i > 0 && (isIdentByte(query[i-1]) || query[i-1] == '.' || query[i-1] == '$') {
+			return true
+		}
+		name, _ := kqlLeadingName(query[i:])
+		if name == "" || strings.Contains(name, "*") {
+			return true
+		}
+		if _, ok := kqlCallAt(query[i:], name); ok {
+			names = append(names, name)
+		}
+		return true
+	})
+	return names
+}
+
+// isCallName reports whether the word can be the name of a called function.
+func isCallName(word string) bool {
+	if word == "" || word[0] >= '0' && word[0] <= '9' {
+		return false
+	}
+	for i := 0; i < len(word); i++ {
+		if !isIdentByte(word[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// precedingWord returns the word before the index, e.g. "in" for "x in (".
+func precedingWord(s string, i int) string {
+	end := i
+	for end > 0 && (s[end-1] == ' ' || s[end-1] == '\t' || s[end-1] == '\n') {
+		end--
+	}
+	start := end
+	for start > 0 && (isIdentByte(s[start-1]) || s[start-1] == '!' || s[start-1] == '~') {
+		start--
+	}
+	return s[start:end]
+}
@@ -1,8 +1,11 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"sort"
 
 	jsoniter "github.com/json-iterator/go"
 )
@@ -38,3 +41,110 @@ func SchemaFromJSON(rc io.Reader) ([]TableSchema, error) {
 
 	return tr, nil
 }
+
+// DefaultDatabaseName is the name of the database when neither Logship nor the
+// datasource settings name one.
+const DefaultDatabaseName = "Default"
+
+// SchemaResponse is the schema of every database, sorted by database name.
+type SchemaResponse struct {
+	Databases []DatabaseSchemaResponse `json:"databases"`
+}
+
+// DatabaseSummary describes a database without its tables.
+type DatabaseSummary struct {
+	Name    string `json:"name"`
+	Tables  int    `json:"tables"`
+	Default bool   `json:"default"`
+}
+
+// DatabaseList is the response of the /databases resource.
+type DatabaseList struct {
+	Databases []DatabaseSummary `json:"databases"`
+}
+
+// SchemaFromResults builds the schema from the rows of a schema.tables.schema query,
+// grouped by their DatabaseName. Rows without a database belong to defaultDatabase.
+// Databases, tables and columns are sorted by name.
+func SchemaFromResults(results []map[string]interface{}, defaultDatabase string) *SchemaResponse {
+	databases := map[string]map[string]*TableSchema{}
+	for _, row := range results {
+		databaseName, _ := row["DatabaseName"].(string)
+		tableName, _ := row["TableName"].(string)
+		columnName, _ := row["ColumnName"].(string)
+		columnType, _ := row["ColumnType"].(string)
+
+		if databaseName == "" {
+			databaseName = defaultDatabase
+		}
+		tables, ok := databases[databaseName]
+		if !ok {
+			tables = map[string]*TableSchema{}
+			databases[databaseName] = tables
+		}
+		table, ok := tables[tableName]
+		if !ok {
+			table = &TableSchema{Name: tableName, Columns: []ColumnSchema{}}
+			tables[tableName] = table
+		}
+		table.Columns = append(table.Columns, ColumnSchema{Name: columnName, Type: columnType})
+	}
+
+	schema := &SchemaResponse{Databases: make([]DatabaseSchemaResponse, 0, len(databases))}
+	for name, tables := range databases {
+		schema.Databases = append(schema.Databases, DatabaseSchemaResponse{Name: name, Tables: sortTables(tables)})
+	}
+	sort.Slice(schema.Databases, func(i, j int) bool {
+		return schema.Databases[i].Name < schema.Databases[j].Name
+	})
+	return schema
+}
+
+func sortTables(tables map[string]*TableSchema) []TableSchema {
+	schemas := make([]TableSchema, 0, len(tables))
+	for _, table := range tables {
+		sort.SliceStable(table.Columns, func(i, j int) bool {
+			return table.Columns[i].Name < table.Columns[j].Name
+		})
+		schemas = append(schemas, *table)
+	}
+	sort.Slice(schemas, func(i, j int) bool {
+		return schemas[i].Name < schemas[j].Name
+	})
+	return schemas
+}
+
+// Database returns the schema of the named database. A database without tables
+// is returned empty so that it can still be selected.
+func (r *SchemaResponse) Database(name string) DatabaseSchemaResponse {
+	for _, db := range r.Databases {
+		if db.Name == name {
+			return db
+		}
+	}
+	return DatabaseSchemaResponse{Name: name, Tables: []TableSchema{}}
+}
+
+// DatabaseList summarizes the databases, marking the default database.
+func (r *SchemaResponse) DatabaseList(defaultDatabase string) DatabaseList {
+	list := DatabaseList{Databases: []DatabaseSummary{}}
+	found := false
+	for _, db := range r.Databases {
+		found = found || db.Name == defaultDatabase
+		list.Databases = append(list.Databases, DatabaseSummary{Name: db.Name, Tables: len(db.Tables), Default: db.Name == defaultDatabase})
+	}
+	if !found {
+		list.Databases = append([]DatabaseSummary{{Name: defaultDatabase, Default: true}}, list.Databases...)
+	}
+	return list
+}
+
+// ETag returns a strong entity tag identifying the content of the schema.
+func (r *SchemaResponse) ETag() (string, error) {
+	b, err := jsoniter.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
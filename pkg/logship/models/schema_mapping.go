@@ -0,0 +1,181 @@
+package models
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	SchemaMappingTable            = "table"
+	SchemaMappingFunction         = "function"
+	SchemaMappingMaterializedView = "materializedView"
+)
+
+// SchemaMapping exposes a table, function or materialized view of a database
+// under a display name, see SchemaMapping in src/types.ts.
+type SchemaMapping struct {
+	Type        string `json:"type"`
+	Value       string `json:"value"`
+	Name        string `json:"name"`
+	Database    string `json:"database"`
+	DisplayName string `json:"displayName"`
+}
+
+// valid reports whether every field of the mapping is set. The config editor
+// saves partially filled mappings, which are ignored.
+func (m SchemaMapping) valid() bool {
+	return m.Type != "" && m.Value != "" && m.Name != "" && m.Database != "" && m.DisplayName != ""
+}
+
+// SchemaMappings are the complete schema mappings of a datasource.
+type SchemaMappings []SchemaMapping
+
+// NewSchemaMappings returns the valid mappings, or nil when mappings are disabled.
+func NewSchemaMappings(enabled bool, mappings []SchemaMapping) SchemaMappings {
+	if !enabled {
+		return nil
+	}
+	valid := SchemaMappings{}
+	for _, m := range mappings {
+		if m.valid() {
+			valid = append(valid, m)
+		}
+	}
+	return valid
+}
+
+// Maps reports whether the object of the database is mapped with one of the types.
+func (ms SchemaMappings) Maps(database string, name string, types ...string) bool {
+	for _, m := range ms {
+		if m.Database == database && m.Name == name && contains(types, m.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterSchema returns the schema restricted to the mapped tables and materialized views.
+// Databases without any mapped table are left out.
+func (ms SchemaMappings) FilterSchema(schema *SchemaResponse) *SchemaResponse {
+	filtered := &SchemaResponse{Databases: []DatabaseSchemaResponse{}}
+	for _, db := range schema.Databases {
+		tables := []TableSchema{}
+		for _, t := range db.Tables {
+			if ms.Maps(db.Name, t.Name, SchemaMappingTable, SchemaMappingMaterializedView) {
+				tables = append(tables, t)
+			}
+		}
+		if len(tables) > 0 {
+			filtered.Databases = append(filtered.Databases, DatabaseSchemaResponse{Name: db.Name, Tables: tables})
+		}
+	}
+	return filtered
+}
+
+// FilterFunctions returns the stored functions of the database that are mapped.
+func (ms SchemaMappings) FilterFunctions(database string, functions []FunctionSchema) []FunctionSchema {
+	filtered := []FunctionSchema{}
+	for _, fn := range functions {
+		if ms.Maps(database, fn.Name, SchemaMappingFunction) {
+			filtered = append(filtered, fn)
+		}
+	}
+	return filtered
+}
+
+// UnmappedReferences returns the sorted tables, materialized views and stored
+// functions that the query references without them being mapped, see queryReferences.
+// Names are resolved in the database unless they are qualified with database(), and
+// wildcards match the tables of the schema. Whether a name is a stored function is
+// looked up in the function catalog of its database. References that cannot be
+// resolved statically are returned as an error.
+func (ms SchemaMappings) UnmappedReferences(query string, database string, schema *SchemaResponse, catalog func(database string) ([]FunctionSchema, error)) ([]string, error) {
+	refs, err := queryReferences(query)
+	if err != nil {
+		return nil, err
+	}
+
+	functions := map[string]map[string]FunctionSchema{}
+	storedFunction := func(database string, name string) (FunctionSchema, bool, error) {
+		if _, ok := functions[database]; !ok {
+			fns, err := catalog(database)
+			if err != nil {
+				return FunctionSchema{}, false, fmt.Errorf("failed to list the stored functions of database %q: %w", database, err)
+			}
+			functions[database] = map[string]FunctionSchema{}
+			for _, fn := range fns {
+				functions[database][fn.Name] = fn
+			}
+		}
+		fn, ok := functions[database][name]
+		return fn, ok, nil
+	}
+
+	unmapped := map[string]bool{}
+	for _, ref := range refs.references {
+		if ref.Function != "" {
+			// arguments of let functions and stored functions with a tabular parameter
+			tabular := false
+			if ref.FunctionDatabase == "" && refs.bound[ref.Function] {
+				tabular = refs.tabular[ref.Function][ref.Index]
+			} else {
+				fnDatabase := ref.FunctionDatabase
+				if fnDatabase == "" {
+					fnDatabase = database
+				}
+				fn, ok, err := storedFunction(fnDatabase, ref.Function)
+				if err != nil {
+					return nil, err
+				}
+				tabular = ok && ref.Index < len(fn.InputParameters) && fn.InputParameters[ref.Index].Type == "table"
+			}
+			if !tabular {
+				continue
+			}
+		}
+
+		db := ref.Database
+		if db == "" {
+			if refs.bound[ref.Name] {
+				continue
+			}
+			db = database
+		}
+		qualified := ref.Name
+		if db != database {
+			qualified = db + "." + ref.Name
+		}
+
+		switch {
+		case strings.Contains(ref.Name, "*"):
+			for _, t := range schema.Database(db).Tables {
+				if matched, _ := path.Match(ref.Name, t.Name); matched && !ms.Maps(db, t.Name, SchemaMappingTable, SchemaMappingMaterializedView) {
+					unmapped[strings.TrimSuffix(qualified, ref.Name)+t.Name] = true
+				}
+			}
+			continue
+		case !ref.Call && ms.Maps(db, ref.Name, SchemaMappingTable, SchemaMappingMaterializedView):
+			continue
+		}
+
+		_, stored, err := storedFunction(db, ref.Name)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case stored && !ms.Maps(db, ref.Name, SchemaMappingFunction):
+			unmapped[qualified] = true
+		case !stored && !ref.Call:
+			unmapped[qualified] = true
+		}
+	}
+
+	names := make([]string, 0, len(unmapped))
+	for name := range unmapped {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,184 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSchemaMappings(t *testing.T) {
+	mappings := []SchemaMapping{
+		{Type: SchemaMappingTable, Value: "Logs", Name: "Logs", Database: "Default", DisplayName: "Logs"},
+		{Type: SchemaMappingTable, Value: "Metrics", Name: "Metrics", Database: "Default"},
+	}
+
+	require.Nil(t, NewSchemaMappings(false, mappings))
+	require.Equal(t, SchemaMappings{mappings[0]}, NewSchemaMappings(true, mappings))
+	require.NotNil(t, NewSchemaMappings(true, nil))
+}
+
+func TestFilterSchema(t *testing.T) {
+	mappings := SchemaMappings{
+		{Type: SchemaMappingTable, Value: "Logs", Name: "Logs", Database: "Default", DisplayName: "Logs"},
+		{Type: SchemaMappingMaterializedView, Value: "Daily", Name: "Daily", Database: "Default", DisplayName: "Daily"},
+		{Type: SchemaMappingFunction, Value: "Audit", Name: "Audit", Database: "Default", DisplayName: "Audit"},
+	}
+	schema := &SchemaResponse{Databases: []DatabaseSchemaResponse{
+		{Name: "Default", Tables: []TableSchema{{Name: "Audit"}, {Name: "Daily"}, {Name: "Logs"}, {Name: "Secrets"}}},
+		{Name: "archive", Tables: []TableSchema{{Name: "Logs"}}},
+	}}
+
+	require.Equal(t, &SchemaResponse{Databases: []DatabaseSchemaResponse{
+		{Name: "Default", Tables: []TableSchema{{Name: "Daily"}, {Name: "Logs"}}},
+	}}, mappings.FilterSchema(schema))
+}
+
+func TestUnmappedReferences(t *testing.T) {
+	mappings := SchemaMappings{
+		{Type: SchemaMappingTable, Value: "Logs", Name: "Logs", Database: "Default", DisplayName: "Logs"},
+		{Type: SchemaMappingTable, Value: "Logs", Name: "Logs", Database: "archive", DisplayName: "Archived logs"},
+		{Type: SchemaMappingFunction, Value: "Errors", Name: "Errors", Database: "Default", DisplayName: "Errors"},
+	}
+	schema := &SchemaResponse{Databases: []DatabaseSchemaResponse{
+		{Name: "Default", Tables: []TableSchema{{Name: "Logs"}, {Name: "LogsRaw"}, {Name: "Secrets"}, {Name: "Secret Table"}}},
+		{Name: "archive", Tables: []TableSchema{{Name: "Logs"}, {Name: "Secrets"}}},
+	}}
+	catalog := func(database string) ([]FunctionSchema, error) {
+		if database != "Default" {
+			return nil, nil
+		}
+		return []FunctionSchema{
+			{Name: "Errors", InputParameters: []ColumnSchema{{Name: "T", Type: "table"}, {Name: "level", Type: "string"}}},
+			{Name: "SecretFn"},
+		}, nil
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		unmapped []string
+		errorIs  assert.ErrorAssertionFunc
+	}{
+		{
+			name:     "mapped table",
+			query:    "Logs | where Level == 'Secrets' | take 10",
+			unmapped: []string{},
+			errorIs:  assert.NoError,
+		},
+		{
+			name:     "column named like a table",
+			query:    "Logs | where Secrets > 0 | project Secrets, LogsRaw = 1",
+			unmapped: []string{},
+			errorIs:  assert.NoError,
+		},
+		{
+			name:     "unmapped table",
+			query:    "Logs | join (Secrets) on Id",
+			unmapped: []string{"Secrets"},
+			errorIs:  assert.NoError,
+		},
+		{
+			name:     "quoted table",
+			query:    "['Secret Table'] | take 10",
+			unmapped: []string{"Secret Table"},
+			errorIs:  assert.NoError,
+		},
+		{
+			name:     "table function",
+			query:    `table("Secrets") | count`,
+			unmapped: []string{"Secrets"},
+			errorIs:  assert.NoError,
+		},
+		{
+			name:     "comments are ignored",
+			query:    "Logs // joined with Secrets before",
+			unmapped: []string{},
+			errorIs:  assert.NoError,
+		},
+		{
+			name:     "sorted and distinct",
+			query:    "union Secrets, ['Secret Table'], Secrets",
+			unmapped: []string{"Secret Table", "Secrets"},
+			errorIs:  assert.NoError,
+		},
+		{
+			name:     "wildcard union",
+			query:    "union Logs*",
+			unmapped: []string{"LogsRaw"},
+			errorIs:  assert.NoError,
+		},
+		{
+			name:     "other database",
+			query:    `database("archive").Logs | union database("archive").Secrets`,
+			unmapped: []string{"archive.Secrets"},
+			errorIs:  assert.NoError,
+		},
+		{
+			name:     "subquery argument",
+			query:    "Logs | where Id in (Secrets | project Id)",
+			unmapped: []string{"Secrets"},
+			errorIs:  assert.NoError,
+		},
+		{
+			name:     "let bound names",
+			query:    "let Secrets = Logs | take 1;\nlet f = (T:(*)) { T | count };\nf(Secrets)",
+			unmapped: []string{},
+			errorIs:  assert.NoError,
+		},
+		{
+			name:     "tabular argument of a let function",
+			query:    "let f = (T:(*), n:int) { T | take n };\nf(Secrets, 1)",
+			unmapped: []string{"Secrets"},
+			errorIs:  assert.NoError,
+		},
+		{
+			name:     "mapped stored function",
+			query:    "Errors(Logs, 'error') | extend n = strlen(Secrets)",
+			unmapped: []string{},
+			errorIs:  assert.NoError,
+		},
+		{
+			name:     "tabular argument of a stored function",
+			query:    "Errors(Secrets, 'error')",
+			unmapped: []string{"Secrets"},
+			errorIs:  assert.NoError,
+		},
+		{
+			name:     "unmapped stored function",
+			query:    "Logs | extend x = 1 | join (SecretFn) on Id",
+			unmapped: []string{"SecretFn"},
+			errorIs:  assert.NoError,
+		},
+		{
+			name:    "computed table name",
+			query:   `table(strcat("Sec", "rets")) | count`,
+			errorIs: assert.Error,
+		},
+		{
+			name:    "table function in a scalar position",
+			query:   `Logs | extend t = table("Secrets")`,
+			errorIs: assert.Error,
+		},
+		{
+			name:    "other cluster",
+			query:   `cluster("other").database("Default").Secrets`,
+			errorIs: assert.Error,
+		},
+		{
+			name:    "search across tables",
+			query:   `search "password"`,
+			errorIs: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unmapped, err := mappings.UnmappedReferences(tt.query, "Default", schema, catalog)
+			tt.errorIs(t, err)
+			if err == nil {
+				require.Equal(t, tt.unmapped, unmapped)
+			}
+		})
+	}
+}
@@ -96,7 +96,7 @@ func TestResponseToFrames(t *testing.T) {
 				t.Errorf("unable to run test '%v', could not load file '%v': %v", tt.name, tt.testFile, err)
 			}
 
-			frames, err := respTable.ToDataFrames("")
+			frames, err := respTable.ToDataFrames("", FrameOptions{})
 			tt.errorIs(t, err)
 			if err != nil {
 				return
@@ -116,7 +116,7 @@ func TestResponseToFrames(t *testing.T) {
 	t.Run("query with no rows", func(t *testing.T) {
 		respTable, err := tableFromJSONFile("no_rows.json")
 		assert.NoError(t, err)
-		frames, err := respTable.ToDataFrames("")
+		frames, err := respTable.ToDataFrames("", FrameOptions{})
 		assert.NoError(t, err)
 		assert.Empty(t, frames)
 	})
@@ -164,7 +164,7 @@ func TestTableResponse_ToLogshipTimeSeries(t *testing.T) {
 				}
 			}
 
-			initialFrames, err := respTable.ToDataFrames("T | select NotActualQuery")
+			initialFrames, err := respTable.ToDataFrames("T | select NotActualQuery", FrameOptions{})
 			require.NoError(t, err)
 
 			require.Equal(t, 1, len(initialFrames))
@@ -179,3 +179,50 @@ func TestTableResponse_ToLogshipTimeSeries(t *testing.T) {
 		})
 	}
 }
+
+func TestSchemaFromResults(t *testing.T) {
+	results := []map[string]interface{}{
+		{"TableName": "b", "ColumnName": "y", "ColumnType": "string"},
+		{"TableName": "a", "ColumnName": "timestamp", "ColumnType": "datetime"},
+		{"DatabaseName": "archive", "TableName": "c", "ColumnName": "z", "ColumnType": "long"},
+		{"TableName": "b", "ColumnName": "x", "ColumnType": "long"},
+	}
+
+	schema := SchemaFromResults(results, "main")
+	require.Equal(t, &SchemaResponse{Databases: []DatabaseSchemaResponse{
+		{Name: "archive", Tables: []TableSchema{
+			{Name: "c", Columns: []ColumnSchema{{Name: "z", Type: "long"}}},
+		}},
+		{Name: "main", Tables: []TableSchema{
+			{Name: "a", Columns: []ColumnSchema{{Name: "timestamp", Type: "datetime"}}},
+			{Name: "b", Columns: []ColumnSchema{{Name: "x", Type: "long"}, {Name: "y", Type: "string"}}},
+		}},
+	}}, schema)
+
+	first, err := schema.ETag()
+	require.NoError(t, err)
+	second, err := SchemaFromResults(results, "main").ETag()
+	require.NoError(t, err)
+	require.Equal(t, first, second)
+
+	require.Equal(t, "archive", schema.Database("archive").Name)
+	require.Equal(t, DatabaseSchemaResponse{Name: "missing", Tables: []TableSchema{}}, schema.Database("missing"))
+}
+
+func TestDatabaseList(t *testing.T) {
+	schema := &SchemaResponse{Databases: []DatabaseSchemaResponse{
+		{Name: "archive", Tables: []TableSchema{{Name: "c"}}},
+		{Name: "main", Tables: []TableSchema{{Name: "a"}, {Name: "b"}}},
+	}}
+
+	require.Equal(t, DatabaseList{Databases: []DatabaseSummary{
+		{Name: "archive", Tables: 1},
+		{Name: "main", Tables: 2, Default: true},
+	}}, schema.DatabaseList("main"))
+
+	require.Equal(t, DatabaseList{Databases: []DatabaseSummary{
+		{Name: "Default", Default: true},
+		{Name: "archive", Tables: 1},
+		{Name: "main", Tables: 2},
+	}}, schema.DatabaseList("Default"))
+}
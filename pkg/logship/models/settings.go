@@ -9,17 +9,52 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 )
 
+const defaultSchemaCacheTTL = 5 * time.Minute
+
+// minEnforcedSchemaCacheTTL is the shortest schema cache TTL when schema mappings are
+// enforced, since every query then needs the schema.
+const minEnforcedSchemaCacheTTL = 30 * time.Second
+
 type DatasourceSettings struct {
 	ClusterURL         string `json:"clusterUrl"`
+	DefaultDatabase    string `json:"defaultDatabase"`
 	CacheMaxAge        string `json:"cacheMaxAge"`
 	DynamicCaching     bool   `json:"dynamicCaching"`
 	EnableUserTracking bool   `json:"enableUserTracking"`
+	ShowStackTrace     bool   `json:"showStackTrace"`
 	AuthType           string `json:"authType"`
 	Username           string `json:"username"`
 	ClientId           string `json:"clientId"`
 	TokenEndpoint      string `json:"tokenEndpoint"`
 	Scope              string `json:"scope"`
 
+	// UseSchemaMapping restricts the schema to the SchemaMappings, EnforceSchemaMapping
+	// additionally rejects queries that reference tables which are not mapped.
+	UseSchemaMapping     bool            `json:"useSchemaMapping"`
+	SchemaMappings       []SchemaMapping `json:"schemaMappings"`
+	EnforceSchemaMapping bool            `json:"enforceSchemaMapping"`
+
+	// Mappings are the complete SchemaMappings when UseSchemaMapping is enabled.
+	Mappings SchemaMappings `json:"-"`
+
+	// Int128AsString returns Int128 columns as strings instead of float64 numbers.
+	Int128AsString bool `json:"int128AsString"`
+
+	// TypeMappings convert the columns of new or unsupported Logship types like
+	// columns of a supported type.
+	TypeMappings ColumnTypeMappings `json:"typeMappings"`
+
+	// QueryFunctions are the saved query fragments that can be called with $__fn.
+	QueryFunctions []QueryFunction `json:"queryFunctions"`
+
+	// SchemaCacheTTLRaw is a duration string set in the datasource settings for how long
+	// the schema is cached before it is refreshed.
+	SchemaCacheTTLRaw string `json:"schemaCacheTtl"`
+
+	// SchemaCacheTTL the parsed duration of SchemaCacheTTLRaw, zero disables the cache
+	// unless schema mappings are enforced.
+	SchemaCacheTTL time.Duration `json:"-"`
+
 	// QueryTimeoutRaw is a duration string set in the datasource settings and corresponds
 	// to the server execution timeout.
 	QueryTimeoutRaw string `json:"queryTimeout"`
@@ -61,6 +96,20 @@ func (d *DatasourceSettings) Load(config backend.DataSourceInstanceSettings) err
 		}
 	}
 
+	if d.SchemaCacheTTLRaw == "" {
+		d.SchemaCacheTTL = defaultSchemaCacheTTL
+	} else {
+		if d.SchemaCacheTTL, err = time.ParseDuration(d.SchemaCacheTTLRaw); err != nil {
+			return err
+		}
+		if d.SchemaCacheTTL < 0 {
+			return fmt.Errorf("schema cache TTL must not be negative")
+		}
+	}
+	if d.EnforceSchemaMapping && d.SchemaCacheTTL < minEnforcedSchemaCacheTTL {
+		d.SchemaCacheTTL = minEnforcedSchemaCacheTTL
+	}
+
 	if d.AuthType == "" {
 		d.AuthType = "jwt"
 	}
@@ -69,9 +118,41 @@ func (d *DatasourceSettings) Load(config backend.DataSourceInstanceSettings) err
 		return err
 	}
 
+	d.Mappings = NewSchemaMappings(d.UseSchemaMapping, d.SchemaMappings)
+
+	if err = validateQueryFunctions(d.QueryFunctions); err != nil {
+		return err
+	}
+
+	// an invalid type mapping only affects the columns of its type, so it is
+	// ignored rather than failing every query of the datasource
+	var invalid []error
+	d.TypeMappings, invalid = d.TypeMappings.valid()
+	for _, err := range invalid {
+		backend.Logger.Warn("ignoring invalid column type mapping", "error", err)
+	}
+
 	return nil
 }
 
+// DatabaseOrDefault returns the database, falling back to the default database
+// of the datasource. The result is empty when neither is set.
+func (d *DatasourceSettings) DatabaseOrDefault(database string) string {
+	if database != "" {
+		return database
+	}
+	return d.DefaultDatabase
+}
+
+// SchemaDatabase returns the database name the schema lists tables under when
+// no database is given.
+func (d *DatasourceSettings) SchemaDatabase(database string) string {
+	if database = d.DatabaseOrDefault(database); database != "" {
+		return database
+	}
+	return DefaultDatabaseName
+}
+
 // formatTimeout creates some sort of MS TimeSpan string for durations
 // that up to an hour. It is used for the servertimeout request property
 // option.
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_SchemaCacheTTL(t *testing.T) {
+	tests := []struct {
+		name     string
+		jsonData string
+		errorIs  assert.ErrorAssertionFunc
+		want     time.Duration
+	}{
+		{name: "default", jsonData: `{}`, errorIs: assert.NoError, want: defaultSchemaCacheTTL},
+		{name: "configured", jsonData: `{"schemaCacheTtl": "1m"}`, errorIs: assert.NoError, want: time.Minute},
+		{name: "disabled", jsonData: `{"schemaCacheTtl": "0s"}`, errorIs: assert.NoError, want: 0},
+		{name: "disabled with enforced mappings", jsonData: `{"schemaCacheTtl": "0s", "enforceSchemaMapping": true}`, errorIs: assert.NoError, want: minEnforcedSchemaCacheTTL},
+		{name: "short with enforced mappings", jsonData: `{"schemaCacheTtl": "1s", "enforceSchemaMapping": true}`, errorIs: assert.NoError, want: minEnforcedSchemaCacheTTL},
+		{name: "negative", jsonData: `{"schemaCacheTtl": "-1m"}`, errorIs: assert.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := &DatasourceSettings{}
+			err := settings.Load(backend.DataSourceInstanceSettings{JSONData: []byte(tt.jsonData)})
+			tt.errorIs(t, err)
+			if err == nil {
+				require.Equal(t, tt.want, settings.SchemaCacheTTL)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	jsoniter "github.com/json-iterator/go"
+)
+
+//  Stored functions:
+//   - FunctionCatalogQuery lists the functions stored in Logship.
+//   - Queries with the "function" query type call a stored function with typed
+//     parameters, e.g. RequestsByHost("web-1", datetime(2023-01-01T00:00:00Z), 5m).
+
+// FunctionCatalogQuery returns a row per stored function with its Name, Parameters,
+// Body and DocString, and the DatabaseName it is stored in.
+const FunctionCatalogQuery = "schema.functions"
+
+const storedFunctionKind = "StoredFunction"
+
+// FunctionParameter is an argument of a stored function call. Value may be a JSON
+// string, number, boolean or, for dynamic parameters, any JSON value.
+type FunctionParameter struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// StoredFunctionSchemas builds the schemas of the stored functions from the rows of
+// the FunctionCatalogQuery, keyed by the database they are stored in. Rows without
+// a database belong to defaultDatabase.
+func StoredFunctionSchemas(results []map[string]interface{}, defaultDatabase string) map[string][]FunctionSchema {
+	functions := map[string][]FunctionSchema{}
+	for _, row := range results {
+		databaseName, _ := row["DatabaseName"].(string)
+		name, _ := row["Name"].(string)
+		parameters, _ := row["Parameters"].(string)
+		body, _ := row["Body"].(string)
+		docString, _ := row["DocString"].(string)
+
+		if databaseName == "" {
+			databaseName = defaultDatabase
+		}
+		functions[databaseName] = append(functions[databaseName], FunctionSchema{
+			Name:            name,
+			Body:            body,
+			FunctionKind:    storedFunctionKind,
+			InputParameters: parseFunctionParameters(parameters),
+			OutputColumns:   []ColumnSchema{},
+			DocString:       docString,
+		})
+	}
+	return functions
+}
+
+// parseFunctionParameters parses a parameter list such as
+// "(host:string, since:timespan = 1h, T:(Timestamp:datetime))".
+// Tabular parameters are given the type "table".
+func parseFunctionParameters(s string) []ColumnSchema {
+	s = strings.TrimSpace(s)
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "("), ")")
+
+	params := []ColumnSchema{}
+	if strings.TrimSpace(s) == "" {
+		return params
+	}
+	for _, p := range splitKQL(s, ",") {
+		name, typ, _ := strings.Cut(p, ":")
+		if defaults := splitKQL(typ, "="); len(defaults) > 1 {
+			typ = defaults[0]
+		}
+		typ = strings.TrimSpace(typ)
+		if strings.HasPrefix(typ, "(") {
+			typ = "table"
+		}
+		params = append(params, ColumnSchema{Name: strings.TrimSpace(name), Type: typ})
+	}
+	return params
+}
+
+// FunctionSchemas merges the saved query functions and the stored functions, sorted by name.
+func FunctionSchemas(saved []QueryFunction, stored []FunctionSchema) []FunctionSchema {
+	schemas := append(SavedFunctionSchemas(saved), stored...)
+	sort.SliceStable(schemas, func(i, j int) bool { return schemas[i].Name < schemas[j].Name })
+	return schemas
+}
+
+// FunctionCall builds the query calling the stored function of the query model
+// with its parameters as KQL literals of their types.
+func (qm *QueryModel) FunctionCall() (string, error) {
+	if !functionNameRE.MatchString(qm.Function) {
+		return "", fmt.Errorf("invalid function name %q", qm.Function)
+	}
+
+	args := make([]string, 0, len(qm.Parameters))
+	for _, p := range qm.Parameters {
+		literal, err := kqlLiteral(p.Type, p.Value)
+		if err != nil {
+			return "", fmt.Errorf("invalid value for parameter %q of function %q: %w", p.Name, qm.Function, err)
+		}
+		args = append(args, literal)
+	}
+	return fmt.Sprintf("%s(%s)", qm.Function, strings.Join(args, ", ")), nil
+}
+
+// kqlLiteral formats the value as a KQL literal of the type. Values are validated
+// so that they cannot change the structure of the query.
+func kqlLiteral(typ string, value interface{}) (string, error) {
+	typ = strings.ToLower(strings.TrimSpace(typ))
+	if value == nil {
+		if typ == "string" {
+			return `""`, nil
+		}
+		if _, ok := scalarTypes[typ]; !ok {
+			return "", fmt.Errorf("unsupported type %q", typ)
+		}
+		return fmt.Sprintf("%s(null)", scalarTypes[typ]), nil
+	}
+
+	switch typ {
+	case "string":
+		return QuoteString(fmt.Sprint(value)), nil
+	case "long", "int":
+		n, err := integerValue(value)
+		if err != nil {
+			return "", err
+		}
+		if typ == "int" {
+			if n < math.MinInt32 || n > math.MaxInt32 {
+				return "", fmt.Errorf("%d is out of range for int", n)
+			}
+			return fmt.Sprintf("int(%d)", n), nil
+		}
+		return strconv.FormatInt(n, 10), nil
+	case "real", "double", "decimal":
+		f, err := floatValue(value)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s(%s)", scalarTypes[typ], strconv.FormatFloat(f, 'g', -1, 64)), nil
+	case "bool", "boolean":
+		switch v := value.(type) {
+		case bool:
+			return strconv.FormatBool(v), nil
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return "", fmt.Errorf("%q is not a bool", v)
+			}
+			return strconv.FormatBool(b), nil
+		}
+		return "", fmt.Errorf("%v is not a bool", value)
+	case "datetime", "date":
+		switch v := value.(type) {
+		case string:
+			if isMacro(v, "timeFrom", "timeTo") {
+				return v, nil
+			}
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return "", fmt.Errorf("%q is not an RFC 3339 datetime", v)
+			}
+			return fmt.Sprintf("datetime(%s)", t.UTC().Format(time.RFC3339Nano)), nil
+		case float64:
+			// epoch milliseconds
+			return fmt.Sprintf("datetime(%s)", time.UnixMilli(int64(v)).UTC().Format(time.RFC3339Nano)), nil
+		}
+		return "", fmt.Errorf("%v is not a datetime", value)
+	case "timespan", "time":
+		switch v := value.(type) {
+		case string:
+			if isMacro(v, "timeInterval") {
+				return v, nil
+			}
+			if _, err := ParseTimespan(v); err != nil {
+				return "", err
+			}
+			return strings.TrimSpace(v), nil
+		case float64:
+			// milliseconds
+			return fmt.Sprintf("%sms", strconv.FormatFloat(v, 'f', -1, 64)), nil
+		}
+		return "", fmt.Errorf("%v is not a timespan", value)
+	case "guid", "uuid":
+		id, err := uuid.Parse(fmt.Sprint(value))
+		if err != nil {
+			return "", fmt.Errorf("%v is not a guid", value)
+		}
+		return fmt.Sprintf("guid(%s)", id), nil
+	case "dynamic":
+		b, err := jsoniter.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("dynamic(%s)", b), nil
+	}
+	return "", fmt.Errorf("unsupported type %q", typ)
+}
+
+// isMacro reports whether s is exactly one of the named macros, e.g. $__timeFrom or $__timeFrom().
+func isMacro(s string, names ...string) bool {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "()")
+	for _, name := range names {
+		if s == "$__"+name {
+			return true
+		}
+	}
+	return false
+}
+
+// scalarTypes maps the parameter types to the KQL type used in their literals.
+var scalarTypes = map[string]string{
+	"long":     "long",
+	"int":      "int",
+	"real":     "real",
+	"double":   "real",
+	"decimal":  "decimal",
+	"bool":     "bool",
+	"boolean":  "bool",
+	"datetime": "datetime",
+	"date":     "datetime",
+	"timespan": "timespan",
+	"time":     "timespan",
+	"guid":     "guid",
+	"uuid":     "guid",
+	"dynamic":  "dynamic",
+}
+
+func integerValue(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v > math.MaxInt64 {
+			return 0, fmt.Errorf("%v is not an integer", v)
+		}
+		return int64(v), nil
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not an integer", v)
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("%v is not an integer", value)
+}
+
+func floatValue(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return 0, fmt.Errorf("%q is not a number", v)
+		}
+		return f, nil
+	}
+	return 0, fmt.Errorf("%v is not a number", value)
+}
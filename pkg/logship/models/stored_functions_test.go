@@ -0,0 +1,120 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoredFunctionSchemas(t *testing.T) {
+	results := []map[string]interface{}{
+		{
+			"Name":       "RequestsByHost",
+			"Parameters": "(host:string, since:timespan = 1h, T:(Timestamp:datetime, Host:string))",
+			"Body":       "{ T | where Host == host }",
+			"DocString":  "Requests of a host",
+		},
+		{"DatabaseName": "archive", "Name": "Everything", "Parameters": "()", "Body": "{ Logs }"},
+	}
+
+	functions := StoredFunctionSchemas(results, "Default")
+	require.Equal(t, []FunctionSchema{{
+		Name:         "RequestsByHost",
+		Body:         "{ T | where Host == host }",
+		FunctionKind: storedFunctionKind,
+		InputParameters: []ColumnSchema{
+			{Name: "host", Type: "string"},
+			{Name: "since", Type: "timespan"},
+			{Name: "T", Type: "table"},
+		},
+		OutputColumns: []ColumnSchema{},
+		DocString:     "Requests of a host",
+	}}, functions["Default"])
+	require.Len(t, functions["archive"], 1)
+	require.Empty(t, functions["archive"][0].InputParameters)
+}
+
+func TestFunctionSchemas(t *testing.T) {
+	stored := []FunctionSchema{{Name: "b", FunctionKind: storedFunctionKind}}
+	saved := []QueryFunction{{Name: "a"}, {Name: "c"}}
+
+	schemas := FunctionSchemas(saved, stored)
+	require.Equal(t, []string{"a", "b", "c"}, []string{schemas[0].Name, schemas[1].Name, schemas[2].Name})
+	require.Equal(t, []string{savedFunctionKind, storedFunctionKind, savedFunctionKind},
+		[]string{schemas[0].FunctionKind, schemas[1].FunctionKind, schemas[2].FunctionKind})
+}
+
+func TestFunctionCall(t *testing.T) {
+	tests := []struct {
+		name       string
+		function   string
+		parameters []FunctionParameter
+		errorIs    assert.ErrorAssertionFunc
+		query      string
+	}{
+		{
+			name:     "no parameters",
+			function: "Everything",
+			errorIs:  assert.NoError,
+			query:    "Everything()",
+		},
+		{
+			name:     "typed parameters",
+			function: "RequestsByHost",
+			parameters: []FunctionParameter{
+				{Name: "host", Type: "string", Value: `web"1`},
+				{Name: "limit", Type: "long", Value: float64(10)},
+				{Name: "ratio", Type: "real", Value: "0.5"},
+				{Name: "errors", Type: "bool", Value: true},
+				{Name: "from", Type: "datetime", Value: "2023-01-01T00:00:00+01:00"},
+				{Name: "to", Type: "datetime", Value: "$__timeTo"},
+				{Name: "step", Type: "timespan", Value: "5m"},
+				{Name: "id", Type: "guid", Value: "74be27de-1e4e-49d9-b579-fe0b331d3642"},
+				{Name: "tags", Type: "dynamic", Value: []interface{}{"a", float64(1)}},
+				{Name: "missing", Type: "long", Value: nil},
+			},
+			errorIs: assert.NoError,
+			query: `RequestsByHost("web\"1", 10, real(0.5), true, datetime(2022-12-31T23:00:00Z), $__timeTo, 5m, ` +
+				`guid(74be27de-1e4e-49d9-b579-fe0b331d3642), dynamic(["a",1]), long(null))`,
+		},
+		{
+			name:     "invalid function name",
+			function: "Logs | take 1; Secrets",
+			errorIs:  assert.Error,
+		},
+		{
+			name:       "fractional long",
+			function:   "F",
+			parameters: []FunctionParameter{{Name: "n", Type: "long", Value: 1.5}},
+			errorIs:    assert.Error,
+		},
+		{
+			name:       "timespan with an injected pipe",
+			function:   "F",
+			parameters: []FunctionParameter{{Name: "step", Type: "timespan", Value: "5m) | take 1 | ("}},
+			errorIs:    assert.Error,
+		},
+		{
+			name:       "datetime that is not a macro",
+			function:   "F",
+			parameters: []FunctionParameter{{Name: "from", Type: "datetime", Value: "$__timeFilter(x)"}},
+			errorIs:    assert.Error,
+		},
+		{
+			name:       "unsupported type",
+			function:   "F",
+			parameters: []FunctionParameter{{Name: "t", Type: "(x:long)", Value: "T"}},
+			errorIs:    assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qm := QueryModel{QueryType: QueryTypeFunction, Function: tt.function, Parameters: tt.parameters}
+			query, err := qm.FunctionCall()
+			tt.errorIs(t, err)
+			require.Equal(t, tt.query, query)
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// TimeShift moves the time range of a query, e.g. "-7d" queries the same
+// time range one week earlier so it can be compared with the current period.
+type TimeShift struct {
+	// Value is the timeShift as the user wrote it, e.g. "-1w".
+	Value  string
+	Offset time.Duration
+	Label  string
+}
+
+// ParseTimeShift parses the timeShift of a query. An empty value returns nil.
+func ParseTimeShift(s string) (*TimeShift, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	offset, err := ParseTimespan(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timeShift: %w", err)
+	}
+	if offset == 0 {
+		return nil, nil
+	}
+
+	label := fmt.Sprintf("(%s ahead)", strings.TrimPrefix(s, "+"))
+	if offset < 0 {
+		label = fmt.Sprintf("(%s ago)", strings.TrimPrefix(s, "-"))
+	}
+
+	return &TimeShift{
+		Value:  s,
+		Offset: offset,
+		Label:  label,
+	}, nil
+}
+
+// ShiftTimeRange returns a copy of the time range moved by the offset.
+func (ts *TimeShift) ShiftTimeRange(tr *backend.TimeRange) *backend.TimeRange {
+	return &backend.TimeRange{
+		From: tr.From.Add(ts.Offset),
+		To:   tr.To.Add(ts.Offset),
+	}
+}
+
+// ShiftFrames moves the time fields of the frames back into the original time range,
+// adds the shift label to the display name of the numeric fields and marks the frame
+// meta. Names are kept, so that joins and filters on the fields keep working.
+func (ts *TimeShift) ShiftFrames(frames data.Frames) {
+	ts.ShiftTimes(frames)
+	for _, frame := range frames {
+		for _, field := range frame.Fields {
+			if !field.Type().Numeric() {
+				continue
+			}
+			if field.Config == nil {
+				field.Config = &data.FieldConfig{}
+			}
+			field.Config.DisplayName = fmt.Sprintf("%s %s", seriesName(field), ts.Label)
+		}
+	}
+}
+
+// ShiftTimes moves the time fields of the frames back into the original time range
+// and marks the frame meta, without labelling any field.
+func (ts *TimeShift) ShiftTimes(frames data.Frames) {
+	for _, frame := range frames {
+		for _, field := range frame.Fields {
+			switch field.Type() {
+			case data.FieldTypeTime:
+				for i := 0; i < field.Len(); i++ {
+					field.Set(i, field.At(i).(time.Time).Add(-ts.Offset))
+				}
+			case data.FieldTypeNullableTime:
+				for i := 0; i < field.Len(); i++ {
+					if t, ok := field.At(i).(*time.Time); ok && t != nil {
+						shifted := t.Add(-ts.Offset)
+						field.Set(i, &shifted)
+					}
+				}
+			}
+		}
+
+		if frame.Meta == nil {
+			frame.Meta = &data.FrameMeta{}
+		}
+		md, _ := frame.Meta.Custom.(LogshipFrameMD)
+		md.TimeShift = ts.Value
+		frame.Meta.Custom = md
+	}
+}
+
+// seriesName returns the display name of a field, or the name Grafana displays by
+// default: the field name followed by its labels.
+func seriesName(field *data.Field) string {
+	if field.Config != nil && field.Config.DisplayName != "" {
+		return field.Config.DisplayName
+	}
+	if len(field.Labels) == 0 {
+		return field.Name
+	}
+
+	keys := make([]string, 0, len(field.Labels))
+	for k := range field.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	labels := make([]string, len(keys))
+	for i, k := range keys {
+		labels[i] = fmt.Sprintf("%s=%q", k, field.Labels[k])
+	}
+	return fmt.Sprintf("%s {%s}", field.Name, strings.Join(labels, ", "))
+}
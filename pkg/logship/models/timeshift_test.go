@@ -0,0 +1,115 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xorcare/pointer"
+)
+
+func TestParseTimeShift(t *testing.T) {
+	tests := []struct {
+		value     string
+		errorIs   assert.ErrorAssertionFunc
+		wantNil   bool
+		offset    time.Duration
+		wantLabel string
+	}{
+		{value: "", errorIs: assert.NoError, wantNil: true},
+		{value: "0d", errorIs: assert.NoError, wantNil: true},
+		{value: "-7d", errorIs: assert.NoError, offset: -7 * day, wantLabel: "(7d ago)"},
+		{value: "-1h", errorIs: assert.NoError, offset: -time.Hour, wantLabel: "(1h ago)"},
+		{value: "2h", errorIs: assert.NoError, offset: 2 * time.Hour, wantLabel: "(2h ahead)"},
+		{value: " -36h ", errorIs: assert.NoError, offset: -36 * time.Hour, wantLabel: "(36h ago)"},
+		{value: "last week", errorIs: assert.Error, wantNil: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			ts, err := ParseTimeShift(tt.value)
+			tt.errorIs(t, err)
+			if tt.wantNil {
+				assert.Nil(t, ts)
+				return
+			}
+			require.NotNil(t, ts)
+			assert.Equal(t, strings.TrimSpace(tt.value), ts.Value)
+			assert.Equal(t, tt.offset, ts.Offset)
+			assert.Equal(t, tt.wantLabel, ts.Label)
+		})
+	}
+}
+
+func TestTimeShift(t *testing.T) {
+	ts, err := ParseTimeShift("-7d")
+	require.NoError(t, err)
+
+	from := time.Date(2020, 9, 22, 18, 0, 0, 0, time.UTC)
+	to := time.Date(2020, 9, 22, 19, 0, 0, 0, time.UTC)
+
+	t.Run("should shift the time range", func(t *testing.T) {
+		tr := ts.ShiftTimeRange(&backend.TimeRange{From: from, To: to})
+		assert.Equal(t, from.Add(-7*day), tr.From)
+		assert.Equal(t, to.Add(-7*day), tr.To)
+	})
+
+	t.Run("should shift frames back and label numeric series", func(t *testing.T) {
+		frame := data.NewFrame("",
+			data.NewField("Timestamp", nil, []*time.Time{pointer.Time(from.Add(-7 * day)), nil}),
+			data.NewField("Time", nil, []time.Time{to.Add(-7 * day), to.Add(-7 * day)}),
+			data.NewField("Host", nil, []string{"a", "b"}),
+			data.NewField("count_", data.Labels{"Host": "a", "Code": "200"}, []*int64{pointer.Int64(1), pointer.Int64(2)}),
+			data.NewField("total", nil, []float64{1, 2}),
+		).SetMeta(&data.FrameMeta{Custom: LogshipFrameMD{ColumnTypes: []string{"DateTime", "DateTime", "String", "Int64", "Float64"}}})
+
+		ts.ShiftFrames(data.Frames{frame})
+
+		assert.Equal(t, from, *frame.Fields[0].At(0).(*time.Time))
+		assert.Nil(t, frame.Fields[0].At(1))
+		assert.Equal(t, to, frame.Fields[1].At(0))
+		for i, name := range []string{"Timestamp", "Time", "Host", "count_", "total"} {
+			assert.Equal(t, name, frame.Fields[i].Name)
+		}
+		assert.Nil(t, frame.Fields[0].Config)
+		assert.Nil(t, frame.Fields[2].Config)
+		assert.Equal(t, `count_ {Code="200", Host="a"} (7d ago)`, frame.Fields[3].Config.DisplayName)
+		assert.Equal(t, "total (7d ago)", frame.Fields[4].Config.DisplayName)
+		assert.Equal(t, data.Labels{"Host": "a", "Code": "200"}, frame.Fields[3].Labels)
+		assert.Equal(t, LogshipFrameMD{ColumnTypes: []string{"DateTime", "DateTime", "String", "Int64", "Float64"}, TimeShift: "-7d"}, frame.Meta.Custom)
+	})
+
+	t.Run("should label the display name of annotated columns", func(t *testing.T) {
+		tr, err := TableFromJSON(strings.NewReader(`{
+			"Columns": [{"Name": "Time", "Type": "DateTime"}, {"Name": "Latency", "Type": "Float64"}, {"Name": "Size", "Type": "Int64"}],
+			"Results": [{"Time": "2020-09-15T19:00:00Z", "Latency": 1.5, "Size": 2}]
+		}`))
+		require.NoError(t, err)
+		frames, err := tr.ToDataFrames(`T | project Time, Latency, // unit=ms displayName="Average latency"
+			Size`, FrameOptions{})
+		require.NoError(t, err)
+
+		ts.ShiftFrames(frames)
+
+		assert.Equal(t, "Average latency (7d ago)", frames[0].Fields[1].Config.DisplayName)
+		assert.Equal(t, "ms", frames[0].Fields[1].Config.Unit)
+		assert.Equal(t, "Size (7d ago)", frames[0].Fields[2].Config.DisplayName)
+	})
+
+	t.Run("should shift times without labelling", func(t *testing.T) {
+		frame := data.NewFrame("",
+			data.NewField("time", nil, []time.Time{to.Add(-7 * day)}),
+			data.NewField("count", nil, []float64{1}),
+		)
+
+		ts.ShiftTimes(data.Frames{frame})
+
+		assert.Equal(t, to, frame.Fields[0].At(0))
+		assert.Nil(t, frame.Fields[1].Config)
+		assert.Equal(t, LogshipFrameMD{TimeShift: "-7d"}, frame.Meta.Custom)
+	})
+}
@@ -0,0 +1,226 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const tick = 100 * time.Nanosecond
+
+// maxTimespanDays is the largest number of days a time.Duration can hold.
+const maxTimespanDays = int64(math.MaxInt64 / int64(day))
+
+// maxClockTimespanDays is the largest number of days a .NET TimeSpan can hold.
+const maxClockTimespanDays = int64(math.MaxInt64 / int64(day/tick))
+
+// timespanUnits maps every KQL timespan literal suffix to its duration.
+var timespanUnits = map[string]time.Duration{
+	"d":            day,
+	"day":          day,
+	"days":         day,
+	"h":            time.Hour,
+	"hr":           time.Hour,
+	"hrs":          time.Hour,
+	"hour":         time.Hour,
+	"hours":        time.Hour,
+	"m":            time.Minute,
+	"min":          time.Minute,
+	"minute":       time.Minute,
+	"minutes":      time.Minute,
+	"s":            time.Second,
+	"sec":          time.Second,
+	"second":       time.Second,
+	"seconds":      time.Second,
+	"ms":           time.Millisecond,
+	"milli":        time.Millisecond,
+	"millis":       time.Millisecond,
+	"millisecond":  time.Millisecond,
+	"milliseconds": time.Millisecond,
+	"microsecond":  time.Microsecond,
+	"microseconds": time.Microsecond,
+	"tick":         tick,
+	"ticks":        tick,
+}
+
+// ParseTimespan parses a KQL timespan literal, e.g. 1d, 1.5h, 30m, 10s, 100ms,
+// 10microsecond, 1tick, time(15 seconds), timespan(2) or time(0.12:34:56.7).
+func ParseTimespan(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	lower := strings.ToLower(s)
+	for _, fn := range []string{"timespan(", "time("} {
+		if strings.HasPrefix(lower, fn) && strings.HasSuffix(lower, ")") {
+			return parseTimespanFunc(s[len(fn) : len(s)-1])
+		}
+	}
+	return parseTimespanUnit(s, false)
+}
+
+// parseTimespanFunc parses the argument of time() or timespan(). A bare number
+// is a number of days, anything containing ':' is in the [-][d.]hh:mm[:ss[.fffffff]] format.
+func parseTimespanFunc(arg string) (time.Duration, error) {
+	arg = strings.TrimSpace(arg)
+	if strings.Contains(arg, ":") {
+		return ParseClockTimespan(arg)
+	}
+	return parseTimespanUnit(arg, true)
+}
+
+func parseTimespanUnit(s string, defaultDays bool) (time.Duration, error) {
+	i := 0
+	if i < len(s) && (s[i] == '-' || s[i] == '+') {
+		i++
+	}
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+		i++
+	}
+
+	number, unitName := s[:i], strings.ToLower(strings.TrimSpace(s[i:]))
+	if number == "" || number == "-" || number == "+" {
+		return 0, fmt.Errorf("invalid timespan %q", s)
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timespan %q: %w", s, err)
+	}
+
+	if unitName == "" && defaultDays {
+		unitName = "d"
+	}
+	unit, ok := timespanUnits[unitName]
+	if !ok {
+		return 0, fmt.Errorf("invalid timespan %q: unknown unit %q", s, unitName)
+	}
+
+	// float64(math.MaxInt64) rounds up to 2^63, which is already out of range
+	ns := math.Round(value * float64(unit))
+	if math.Abs(ns) >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid timespan %q: out of range", s)
+	}
+	return time.Duration(ns), nil
+}
+
+// ParseClockTimespan parses a timespan in the [-][d.]hh:mm[:ss[.fffffff]] format
+// used by Logship when returning TimeSpan values.
+func ParseClockTimespan(s string) (time.Duration, error) {
+	ts, err := parseClockTimespan(s)
+	if err != nil {
+		return 0, err
+	}
+	if ts.days > maxTimespanDays {
+		return 0, fmt.Errorf("invalid timespan %q: days out of range", s)
+	}
+
+	d := time.Duration(ts.days)*day +
+		time.Duration(ts.hours)*time.Hour +
+		time.Duration(ts.minutes)*time.Minute +
+		time.Duration(ts.seconds)*time.Second +
+		time.Duration(ts.ticks)*tick
+	if d < 0 {
+		return 0, fmt.Errorf("invalid timespan %q: out of range", s)
+	}
+	if ts.negative {
+		return -d, nil
+	}
+	return d, nil
+}
+
+// ClockTimespanMilliseconds parses a timespan like ParseClockTimespan into
+// milliseconds. Unlike a time.Duration it covers the whole range of .NET TimeSpan
+// values, from TimeSpan.MinValue to TimeSpan.MaxValue.
+func ClockTimespanMilliseconds(s string) (float64, error) {
+	ts, err := parseClockTimespan(s)
+	if err != nil {
+		return 0, err
+	}
+
+	ms := float64(ts.days)*float64(day/time.Millisecond) +
+		float64(ts.hours)*float64(time.Hour/time.Millisecond) +
+		float64(ts.minutes)*float64(time.Minute/time.Millisecond) +
+		float64(ts.seconds)*float64(time.Second/time.Millisecond) +
+		float64(ts.ticks)/float64(time.Millisecond/tick)
+	if ts.negative {
+		return -ms, nil
+	}
+	return ms, nil
+}
+
+// clockTimespan are the components of a timespan in the [-][d.]hh:mm[:ss[.fffffff]] format.
+type clockTimespan struct {
+	negative                             bool
+	days, hours, minutes, seconds, ticks int64
+}
+
+func parseClockTimespan(s string) (clockTimespan, error) {
+	var ts clockTimespan
+	v := strings.TrimSpace(s)
+	ts.negative = strings.HasPrefix(v, "-")
+	v = strings.TrimPrefix(v, "-")
+
+	parts := strings.Split(v, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return ts, fmt.Errorf("invalid timespan %q", s)
+	}
+
+	var err error
+	hours := parts[0]
+	if idx := strings.Index(hours, "."); idx >= 0 {
+		if ts.days, err = strconv.ParseInt(hours[:idx], 10, 64); err != nil {
+			return ts, fmt.Errorf("invalid timespan %q: %w", s, err)
+		}
+		hours = hours[idx+1:]
+	}
+	if ts.days < 0 || ts.days > maxClockTimespanDays {
+		return ts, fmt.Errorf("invalid timespan %q: days out of range", s)
+	}
+
+	if ts.hours, err = parseClockComponent(hours, 23); err != nil {
+		return ts, fmt.Errorf("invalid timespan %q: hours %w", s, err)
+	}
+	if ts.minutes, err = parseClockComponent(parts[1], 59); err != nil {
+		return ts, fmt.Errorf("invalid timespan %q: minutes %w", s, err)
+	}
+
+	if len(parts) == 3 {
+		seconds := parts[2]
+		if idx := strings.Index(seconds, "."); idx >= 0 {
+			if ts.ticks, err = parseTicks(seconds[idx+1:]); err != nil {
+				return ts, fmt.Errorf("invalid timespan %q: fraction %w", s, err)
+			}
+			seconds = seconds[:idx]
+		}
+		if ts.seconds, err = parseClockComponent(seconds, 59); err != nil {
+			return ts, fmt.Errorf("invalid timespan %q: seconds %w", s, err)
+		}
+	}
+	return ts, nil
+}
+
+func parseClockComponent(s string, max int64) (int64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("is empty")
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if v < 0 || v > max {
+		return 0, fmt.Errorf("%d is out of range", v)
+	}
+	return v, nil
+}
+
+// parseTicks converts up to seven fractional second digits into 100ns ticks.
+func parseTicks(s string) (int64, error) {
+	if s == "" || len(s) > 7 {
+		return 0, fmt.Errorf("must have between 1 and 7 digits")
+	}
+	v, err := strconv.ParseInt(s+strings.Repeat("0", 7-len(s)), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return v, nil
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTimespan(t *testing.T) {
+	tests := []struct {
+		value   string
+		errorIs assert.ErrorAssertionFunc
+		want    time.Duration
+	}{
+		{value: "2d", errorIs: assert.NoError, want: 48 * time.Hour},
+		{value: "1.5h", errorIs: assert.NoError, want: 90 * time.Minute},
+		{value: "30m", errorIs: assert.NoError, want: 30 * time.Minute},
+		{value: "10s", errorIs: assert.NoError, want: 10 * time.Second},
+		{value: "0.1s", errorIs: assert.NoError, want: 100 * time.Millisecond},
+		{value: "100ms", errorIs: assert.NoError, want: 100 * time.Millisecond},
+		{value: "10microsecond", errorIs: assert.NoError, want: 10 * time.Microsecond},
+		{value: "1tick", errorIs: assert.NoError, want: 100 * time.Nanosecond},
+		{value: "3days", errorIs: assert.NoError, want: 72 * time.Hour},
+		{value: "5minutes", errorIs: assert.NoError, want: 5 * time.Minute},
+		{value: "time(15 seconds)", errorIs: assert.NoError, want: 15 * time.Second},
+		{value: "time(2)", errorIs: assert.NoError, want: 48 * time.Hour},
+		{value: "timespan(1h)", errorIs: assert.NoError, want: time.Hour},
+		{value: "time(0.12:34:56.7)", errorIs: assert.NoError, want: 12*time.Hour + 34*time.Minute + 56*time.Second + 700*time.Millisecond},
+		{value: "-1d", errorIs: assert.NoError, want: -24 * time.Hour},
+		{value: "100", errorIs: assert.Error},
+		{value: "1y", errorIs: assert.Error},
+		{value: "h", errorIs: assert.Error},
+		{value: "$__timeInterval", errorIs: assert.Error},
+		{value: "106751d", errorIs: assert.NoError, want: 106751 * 24 * time.Hour},
+		{value: "1000000000000d", errorIs: assert.Error},
+		{value: "-1000000000000d", errorIs: assert.Error},
+		{value: "time(1000000000000)", errorIs: assert.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			d, err := ParseTimespan(tt.value)
+			tt.errorIs(t, err)
+			assert.Equal(t, tt.want, d)
+		})
+	}
+}
+
+func TestParseClockTimespan(t *testing.T) {
+	tests := []struct {
+		value   string
+		errorIs assert.ErrorAssertionFunc
+		want    time.Duration
+	}{
+		{value: "00:00:00", errorIs: assert.NoError, want: 0},
+		{value: "00:00:00.0000001", errorIs: assert.NoError, want: 100 * time.Nanosecond},
+		{value: "01:02:03", errorIs: assert.NoError, want: time.Hour + 2*time.Minute + 3*time.Second},
+		{value: "1.02:03:04.5", errorIs: assert.NoError, want: 26*time.Hour + 3*time.Minute + 4*time.Second + 500*time.Millisecond},
+		{value: "-00:00:01.25", errorIs: assert.NoError, want: -1250 * time.Millisecond},
+		{value: "106751.23:47:16.8547758", errorIs: assert.NoError, want: 106751*day + 23*time.Hour + 47*time.Minute + 16*time.Second + 8547758*tick},
+		{value: "10675199.02:48:05.4775807", errorIs: assert.Error},
+		{value: "-2.00:00:00", errorIs: assert.NoError, want: -2 * day},
+		{value: " 00:00:00.5 ", errorIs: assert.NoError, want: 500 * time.Millisecond},
+		{value: "00:00:59.9999999", errorIs: assert.NoError, want: time.Minute - tick},
+		{value: "12:30", errorIs: assert.NoError, want: 12*time.Hour + 30*time.Minute},
+		{value: "-1.-01:00:00", errorIs: assert.Error},
+		{value: "1.:00:00", errorIs: assert.Error},
+		{value: "00:00:", errorIs: assert.Error},
+		{value: "00:00:00.", errorIs: assert.Error},
+		{value: "00:00:60", errorIs: assert.Error},
+		{value: "24:00:00", errorIs: assert.Error},
+		{value: "00:60:00", errorIs: assert.Error},
+		{value: "00:00:00.12345678", errorIs: assert.Error},
+		{value: "1:2:3:4", errorIs: assert.Error},
+		{value: "abc", errorIs: assert.Error},
+		{value: "", errorIs: assert.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			d, err := ParseClockTimespan(tt.value)
+			tt.errorIs(t, err)
+			assert.Equal(t, tt.want, d)
+		})
+	}
+}
+
+func TestClockTimespanMilliseconds(t *testing.T) {
+	tests := []struct {
+		value   string
+		errorIs assert.ErrorAssertionFunc
+		want    float64
+	}{
+		{value: "00:00:00", errorIs: assert.NoError, want: 0},
+		{value: "1.02:03:04.5", errorIs: assert.NoError, want: 93784500},
+		{value: "-00:00:01.25", errorIs: assert.NoError, want: -1250},
+		{value: "00:00:00.0000001", errorIs: assert.NoError, want: 0.0001},
+		{value: "10675199.02:48:05.4775807", errorIs: assert.NoError, want: 922337203685477.5807},   // TimeSpan.MaxValue
+		{value: "-10675199.02:48:05.4775808", errorIs: assert.NoError, want: -922337203685477.5808}, // TimeSpan.MinValue
+		{value: "10675200.00:00:00", errorIs: assert.Error},
+		{value: "00:00:60", errorIs: assert.Error},
+		{value: "abc", errorIs: assert.Error},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			ms, err := ClockTimespanMilliseconds(tt.value)
+			tt.errorIs(t, err)
+			assert.Equal(t, tt.want, ms)
+		})
+	}
+}
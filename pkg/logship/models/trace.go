@@ -0,0 +1,197 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	jsoniter "github.com/json-iterator/go"
+)
+
+// TraceOptions name the columns that spans are built from. Columns that are not
+// named are detected from the column names.
+type TraceOptions struct {
+	TraceIDColumn       string   `json:"traceIdColumn"`
+	SpanIDColumn        string   `json:"spanIdColumn"`
+	ParentSpanIDColumn  string   `json:"parentSpanIdColumn"`
+	OperationNameColumn string   `json:"operationNameColumn"`
+	ServiceNameColumn   string   `json:"serviceNameColumn"`
+	StartTimeColumn     string   `json:"startTimeColumn"`
+	DurationColumn      string   `json:"durationColumn"`
+	SpanTagsColumns     []string `json:"spanTagsColumns"`
+}
+
+// column names, in order of preference, used to detect the span columns
+var (
+	traceIDColumns       = []string{"traceid", "trace_id"}
+	spanIDColumns        = []string{"spanid", "span_id", "id"}
+	parentSpanIDColumns  = []string{"parentspanid", "parent_span_id", "parentid", "parent_id"}
+	operationNameColumns = []string{"operationname", "operation_name", "spanname", "span_name", "name", "operation"}
+	serviceNameColumns   = []string{"servicename", "service_name", "service"}
+	startTimeColumns     = []string{"starttime", "start_time", "timestamp", "time", "start"}
+	durationColumns      = []string{"duration", "durationms", "duration_ms", "elapsed"}
+	spanTagsColumns      = []string{"tags", "attributes", "properties"}
+)
+
+// TraceKeyValue is a tag of a span in Grafana's trace frame.
+type TraceKeyValue struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// TraceFrame converts a query result with a row per span into the trace frame
+// of Grafana's trace view. Start times and durations are in milliseconds. When no
+// tags column is named or detected, the remaining columns become the span tags.
+func TraceFrame(frame *data.Frame, opts TraceOptions) (*data.Frame, error) {
+	finder := newColumnFinder(frame)
+	required := func(option string, names []string, accept func(*data.Field) bool, what string) (*data.Field, error) {
+		f, err := finder.find(option, names, accept)
+		if err == nil && f == nil {
+			err = fmt.Errorf("trace format requires a %s column", what)
+		}
+		return f, err
+	}
+
+	traceID, err := required(opts.TraceIDColumn, traceIDColumns, anyField, "trace ID")
+	if err != nil {
+		return nil, err
+	}
+	spanID, err := required(opts.SpanIDColumn, spanIDColumns, anyField, "span ID")
+	if err != nil {
+		return nil, err
+	}
+	startTime, err := required(opts.StartTimeColumn, startTimeColumns, isTimeField, "start time")
+	if err != nil {
+		return nil, err
+	}
+	duration, err := required(opts.DurationColumn, durationColumns, isDurationField, "duration")
+	if err != nil {
+		return nil, err
+	}
+	parentSpanID, err := finder.find(opts.ParentSpanIDColumn, parentSpanIDColumns, anyField)
+	if err != nil {
+		return nil, err
+	}
+	operationName, err := finder.find(opts.OperationNameColumn, operationNameColumns, anyField)
+	if err != nil {
+		return nil, err
+	}
+	serviceName, err := finder.find(opts.ServiceNameColumn, serviceNameColumns, anyField)
+	if err != nil {
+		return nil, err
+	}
+
+	tagFields := []*data.Field{}
+	for _, name := range opts.SpanTagsColumns {
+		f, err := finder.find(name, nil, anyField)
+		if err != nil {
+			return nil, err
+		}
+		tagFields = append(tagFields, f)
+	}
+	if len(tagFields) == 0 {
+		if f, _ := finder.find("", spanTagsColumns, anyField); f != nil {
+			tagFields = append(tagFields, f)
+		} else {
+			tagFields = finder.unused()
+		}
+	}
+
+	rows := frame.Rows()
+	out := data.NewFrame(frame.Name,
+		data.NewField("traceID", nil, make([]string, rows)),
+		data.NewField("spanID", nil, make([]string, rows)),
+		data.NewField("parentSpanID", nil, make([]*string, rows)),
+		data.NewField("operationName", nil, make([]string, rows)),
+		data.NewField("serviceName", nil, make([]string, rows)),
+		data.NewField("startTime", nil, make([]float64, rows)),
+		data.NewField("duration", nil, make([]float64, rows)),
+		data.NewField("tags", nil, make([]json.RawMessage, rows)),
+	)
+	for i := 0; i < rows; i++ {
+		start, ok := timeAt(startTime, i)
+		if !ok {
+			return nil, fmt.Errorf("span in row %d has no start time", i)
+		}
+		d, err := durationMSAt(duration, i)
+		if err != nil {
+			return nil, fmt.Errorf("span in row %d: %w", i, err)
+		}
+		tags, err := spanTags(tagFields, i)
+		if err != nil {
+			return nil, err
+		}
+
+		out.Fields[0].Set(i, stringAt(traceID, i))
+		out.Fields[1].Set(i, stringAt(spanID, i))
+		if parent := stringAt(parentSpanID, i); parent != "" {
+			out.Fields[2].Set(i, &parent)
+		}
+		out.Fields[3].Set(i, stringAt(operationName, i))
+		out.Fields[4].Set(i, stringAt(serviceName, i))
+		out.Fields[5].Set(i, float64(start.UnixNano())/float64(time.Millisecond))
+		out.Fields[6].Set(i, d)
+		out.Fields[7].Set(i, tags)
+	}
+
+	out.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTrace}
+	if frame.Meta != nil {
+		out.Meta.ExecutedQueryString = frame.Meta.ExecutedQueryString
+	}
+	return out, nil
+}
+
+func isDurationField(f *data.Field) bool {
+	return isNumericField(f) || isStringField(f)
+}
+
+// durationMSAt reads a duration in milliseconds from a number of milliseconds or a
+// timespan such as 00:00:01.5 or 1.5s.
+func durationMSAt(f *data.Field, i int) (float64, error) {
+	if isNumericField(f) {
+		v, _ := floatAt(f, i)
+		return v, nil
+	}
+
+	s := strings.TrimSpace(stringAt(f, i))
+	if s == "" {
+		return 0, nil
+	}
+	d, err := ParseClockTimespan(s)
+	if err != nil {
+		if d, err = ParseTimespan(s); err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+	}
+	return float64(d) / float64(time.Millisecond), nil
+}
+
+// spanTags returns the tags of a span as key/value pairs. JSON objects in a column
+// contribute each of their properties, other non-null values are keyed by the column name.
+func spanTags(fields []*data.Field, i int) (json.RawMessage, error) {
+	tags := []TraceKeyValue{}
+	for _, f := range fields {
+		v, ok := f.ConcreteAt(i)
+		if !ok {
+			continue
+		}
+
+		var object map[string]interface{}
+		if s, isString := v.(string); isString && jsoniter.UnmarshalFromString(s, &object) == nil && object != nil {
+			keys := make([]string, 0, len(object))
+			for k := range object {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				tags = append(tags, TraceKeyValue{Key: k, Value: object[k]})
+			}
+			continue
+		}
+		tags = append(tags, TraceKeyValue{Key: f.Name, Value: v})
+	}
+	return jsoniter.Marshal(tags)
+}
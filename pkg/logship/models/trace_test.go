@@ -0,0 +1,105 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xorcare/pointer"
+)
+
+func TestTraceFrame(t *testing.T) {
+	t1 := time.Date(2023, 1, 1, 10, 0, 0, 0, time.UTC)
+	t2 := t1.Add(1500 * time.Microsecond)
+	ms1 := float64(t1.UnixMilli())
+	ms2 := ms1 + 1.5
+
+	spans := func() *data.Frame {
+		return data.NewFrame("",
+			nullableField("TraceId", "t1", "t1"),
+			nullableField("SpanId", "a", "b"),
+			nullableField("ParentId", "", "a"),
+			nullableField("Name", "GET /", "SELECT"),
+			nullableField("Service", "web", "db"),
+			nullableField("Start", t1, t2),
+			nullableField("Elapsed", "00:00:00.0025", "500us"),
+			nullableField("Host", "web-1", nil),
+			nullableField("Attributes", `{"status":200,"method":"GET"}`, `{}`),
+			nullableField("DurationMs", 2.5, 0.5),
+		)
+	}
+
+	tests := []struct {
+		name    string
+		opts    TraceOptions
+		errorIs assert.ErrorAssertionFunc
+		frame   *data.Frame
+	}{
+		{
+			name:    "detected columns",
+			errorIs: assert.NoError,
+			frame: data.NewFrame("",
+				data.NewField("traceID", nil, []string{"t1", "t1"}),
+				data.NewField("spanID", nil, []string{"a", "b"}),
+				data.NewField("parentSpanID", nil, []*string{nil, pointer.String("a")}),
+				data.NewField("operationName", nil, []string{"GET /", "SELECT"}),
+				data.NewField("serviceName", nil, []string{"web", "db"}),
+				data.NewField("startTime", nil, []float64{ms1, ms2}),
+				data.NewField("duration", nil, []float64{2.5, 0.5}),
+				data.NewField("tags", nil, []json.RawMessage{
+					json.RawMessage(`[{"key":"method","value":"GET"},{"key":"status","value":200}]`),
+					json.RawMessage(`[]`),
+				}),
+			).SetMeta(&data.FrameMeta{PreferredVisualization: data.VisTypeTrace}),
+		},
+		{
+			name: "overridden columns",
+			opts: TraceOptions{
+				DurationColumn:  "DurationMs",
+				SpanTagsColumns: []string{"Host"},
+			},
+			errorIs: assert.NoError,
+			frame: data.NewFrame("",
+				data.NewField("traceID", nil, []string{"t1", "t1"}),
+				data.NewField("spanID", nil, []string{"a", "b"}),
+				data.NewField("parentSpanID", nil, []*string{nil, pointer.String("a")}),
+				data.NewField("operationName", nil, []string{"GET /", "SELECT"}),
+				data.NewField("serviceName", nil, []string{"web", "db"}),
+				data.NewField("startTime", nil, []float64{ms1, ms2}),
+				data.NewField("duration", nil, []float64{2.5, 0.5}),
+				data.NewField("tags", nil, []json.RawMessage{
+					json.RawMessage(`[{"key":"Host","value":"web-1"}]`),
+					json.RawMessage(`[]`),
+				}),
+			).SetMeta(&data.FrameMeta{PreferredVisualization: data.VisTypeTrace}),
+		},
+		{
+			name:    "missing column",
+			opts:    TraceOptions{TraceIDColumn: "Missing"},
+			errorIs: assert.Error,
+		},
+		{
+			name:    "start time that is not a datetime",
+			opts:    TraceOptions{StartTimeColumn: "Service"},
+			errorIs: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := TraceFrame(spans(), tt.opts)
+			tt.errorIs(t, err)
+			if tt.frame != nil {
+				require.Equal(t, tt.frame, frame)
+			}
+		})
+	}
+
+	t.Run("requires the span columns", func(t *testing.T) {
+		_, err := TraceFrame(data.NewFrame("", data.NewField("TraceId", nil, []string{"t1"})), TraceOptions{})
+		require.EqualError(t, err, "trace format requires a span ID column")
+	})
+}
@@ -10,11 +10,14 @@ type options struct {
 type RequestPayload struct {
 	Query       string      `json:"query"`
 	QuerySource string      `json:"querySource"`
+	Database    string      `json:"database,omitempty"`
 	Properties  *Properties `json:"properties,omitempty"`
 }
 
 type LogshipFrameMD struct {
 	ColumnTypes []string
+	TimeShift   string                  `json:",omitempty"`
+	QueryErrors []TokenizedErrorMessage `json:",omitempty"` // token positions refer to the executed query string
 }
 
 // error body,
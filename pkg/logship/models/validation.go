@@ -0,0 +1,144 @@
+package models
+
+import (
+	"strings"
+)
+
+// validationSuffix keeps Logship from returning any rows while validating a query.
+const validationSuffix = "\n| take 0"
+
+// ValidationRequest is the body of a /validate resource request.
+type ValidationRequest struct {
+	Query      string `json:"query"`
+	Database   string `json:"database"`
+	From       int64  `json:"from"` // epoch milliseconds, defaults to an hour ago
+	To         int64  `json:"to"`   // epoch milliseconds, defaults to now
+	IntervalMS int64  `json:"intervalMs"`
+}
+
+// ValidationResponse is the result of a /validate resource request.
+type ValidationResponse struct {
+	Valid       bool         `json:"valid"`
+	Query       string       `json:"query"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// Diagnostic is a problem in a query. Start and End are offsets in the query
+// as written in the editor, before macro interpolation, in the UTF-16 code units
+// the editor positions its markers with.
+type Diagnostic struct {
+	Message  string `json:"message"`
+	Severity string `json:"severity"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+}
+
+// ValidationQuery returns the query to send to Logship to validate the interpolated query.
+func ValidationQuery(interpolated string) string {
+	return strings.TrimRight(interpolated, " \t\r\n;") + validationSuffix
+}
+
+// NewValidationResponse converts the error response of a validation query into diagnostics.
+// A nil error response means the query is valid.
+func NewValidationResponse(original string, interpolated string, er *ErrorResponse) ValidationResponse {
+	res := ValidationResponse{
+		Valid:       er == nil,
+		Query:       interpolated,
+		Diagnostics: []Diagnostic{},
+	}
+	if er == nil {
+		return res
+	}
+
+	m := newOffsetMapper(original, interpolated)
+	for _, e := range er.Errors {
+		if len(e.Tokens) == 0 {
+			res.Diagnostics = append(res.Diagnostics, QueryDiagnostic(original, e.Message))
+			continue
+		}
+		for _, t := range e.Tokens {
+			start, end := utf16Offset(original, m.toOriginal(t.Start, false)), utf16Offset(original, m.toOriginal(t.End, true))
+			res.Diagnostics = append(res.Diagnostics, Diagnostic{Message: e.Message, Severity: "error", Start: start, End: end})
+		}
+	}
+
+	if len(res.Diagnostics) == 0 {
+		res.Diagnostics = append(res.Diagnostics, QueryDiagnostic(original, er.Message))
+	}
+	return res
+}
+
+// QueryDiagnostic returns an error diagnostic spanning the whole query.
+func QueryDiagnostic(query string, message string) Diagnostic {
+	return Diagnostic{Message: message, Severity: "error", Start: 0, End: utf16Offset(query, len(query))}
+}
+
+// utf16Offset converts a byte offset in s into UTF-16 code units.
+func utf16Offset(s string, offset int) int {
+	n := 0
+	for i, r := range s {
+		if i >= offset {
+			break
+		}
+		// runes outside the basic multilingual plane are surrogate pairs
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// offsetMapper maps byte offsets in an interpolated query back to the original query.
+// Offsets before the first and after the last interpolated macro map exactly,
+// offsets in between map to the span of the original query that was changed.
+type offsetMapper struct {
+	prefix       int
+	originalEnd  int
+	rewrittenEnd int
+	originalLen  int
+	rewrittenLen int
+}
+
+func newOffsetMapper(original string, rewritten string) offsetMapper {
+	prefix := 0
+	for prefix < len(original) && prefix < len(rewritten) && original[prefix] == rewritten[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(original)-prefix && suffix < len(rewritten)-prefix &&
+		original[len(original)-1-suffix] == rewritten[len(rewritten)-1-suffix] {
+		suffix++
+	}
+	return offsetMapper{
+		prefix:       prefix,
+		originalEnd:  len(original) - suffix,
+		rewrittenEnd: len(rewritten) - suffix,
+		originalLen:  len(original),
+		rewrittenLen: len(rewritten),
+	}
+}
+
+func (m offsetMapper) toOriginal(offset int, end bool) int {
+	switch {
+	case offset <= m.prefix:
+		return clamp(offset, 0, m.originalLen)
+	case offset >= m.rewrittenEnd:
+		return clamp(offset-m.rewrittenEnd+m.originalEnd, 0, m.originalLen)
+	case end:
+		return m.originalEnd
+	default:
+		return m.prefix
+	}
+}
+
+func clamp(v int, min int, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidationQuery(t *testing.T) {
+	assert.Equal(t, "T | take 5\n| take 0", ValidationQuery("T | take 5;\n"))
+}
+
+func TestNewValidationResponse(t *testing.T) {
+	t.Run("should be valid without an error response", func(t *testing.T) {
+		res := NewValidationResponse("T", "T", nil)
+		assert.True(t, res.Valid)
+		assert.Empty(t, res.Diagnostics)
+	})
+
+	t.Run("should map token positions back to the original query", func(t *testing.T) {
+		original := "T | where $__timeFilter(Timestamp) | sumarize count()"
+		interpolated := "T | where Timestamp >= datetime(2020-01-01T00:00:00Z) and Timestamp <= datetime(2020-01-02T00:00:00Z) | sumarize count()"
+		start := len(interpolated) - len("sumarize count()")
+
+		res := NewValidationResponse(original, interpolated, &ErrorResponse{
+			Message: "Syntax error",
+			Errors: []TokenizedErrorMessage{
+				{Message: "Unknown operator 'sumarize'", Tokens: []Token{{Start: start, End: start + len("sumarize")}}},
+				{Message: "Bad time filter", Tokens: []Token{{Start: 20, End: 30}}},
+				{Message: "Before the macro", Tokens: []Token{{Start: 4, End: 9}}},
+			},
+		})
+
+		assert.False(t, res.Valid)
+		assert.Equal(t, interpolated, res.Query)
+		originalStart := len(original) - len("sumarize count()")
+		assert.Equal(t, []Diagnostic{
+			{Message: "Unknown operator 'sumarize'", Severity: "error", Start: originalStart, End: originalStart + len("sumarize")},
+			{Message: "Bad time filter", Severity: "error", Start: len("T | where "), End: len("T | where $__timeFilter(Timestamp")},
+			{Message: "Before the macro", Severity: "error", Start: 4, End: 9},
+		}, res.Diagnostics)
+	})
+
+	t.Run("should clamp positions in the validation suffix", func(t *testing.T) {
+		res := NewValidationResponse("T |", "T |", &ErrorResponse{
+			Errors: []TokenizedErrorMessage{{Message: "Unexpected end", Tokens: []Token{{Start: 4, End: 20}}}},
+		})
+		assert.Equal(t, []Diagnostic{{Message: "Unexpected end", Severity: "error", Start: 3, End: 3}}, res.Diagnostics)
+	})
+
+	t.Run("should count positions in UTF-16 code units", func(t *testing.T) {
+		original := "T | where Name == 'café 🚀' and $__timeFilter() | sumarize count()"
+		interpolated := "T | where Name == 'café 🚀' and Timestamp >= datetime(2020-01-01T00:00:00Z) | sumarize count()"
+		start := strings.Index(interpolated, "sumarize")
+
+		res := NewValidationResponse(original, interpolated, &ErrorResponse{
+			Errors: []TokenizedErrorMessage{
+				{Message: "Unknown operator 'sumarize'", Tokens: []Token{{Start: start, End: start + len("sumarize")}}},
+				{Message: "Unknown column Name", Tokens: []Token{{Start: 10, End: 14}}},
+				{Message: "No tokens"},
+			},
+		})
+
+		// é is one code unit and two bytes, 🚀 is two code units and four bytes
+		originalStart := len([]rune(original[:strings.Index(original, "sumarize")])) + 1
+		assert.Equal(t, []Diagnostic{
+			{Message: "Unknown operator 'sumarize'", Severity: "error", Start: originalStart, End: originalStart + len("sumarize")},
+			{Message: "Unknown column Name", Severity: "error", Start: 10, End: 14},
+			{Message: "No tokens", Severity: "error", Start: 0, End: len([]rune(original)) + 1},
+		}, res.Diagnostics)
+	})
+
+	t.Run("should fall back to the error message", func(t *testing.T) {
+		res := NewValidationResponse("T", "T", &ErrorResponse{Message: "Table T not found"})
+		assert.Equal(t, []Diagnostic{{Message: "Table T not found", Severity: "error", Start: 0, End: 1}}, res.Diagnostics)
+	})
+}
@@ -0,0 +1,209 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+const (
+	VariableSortNone        = "none"
+	VariableSortAsc         = "asc"
+	VariableSortDesc        = "desc"
+	VariableSortNumericAsc  = "numericAsc"
+	VariableSortNumericDesc = "numericDesc"
+)
+
+// VariableValue is an option of a template variable.
+type VariableValue struct {
+	Text  string `json:"text"`
+	Value string `json:"value"`
+}
+
+// VariableOptions control how query results become template variable values.
+type VariableOptions struct {
+	// Regex filters the values by their text. Like in Grafana it may be written as
+	// /pattern/flags, and a capture group or the named groups text and value
+	// replace the text and value.
+	Regex string `json:"regex"`
+	// Sort is one of the VariableSort constants. It defaults to VariableSortNone, which
+	// keeps the order of the query for Grafana to sort as set in the variable.
+	Sort string `json:"sort"`
+}
+
+// VariableRequest is the body of a /variables resource request.
+type VariableRequest struct {
+	VariableOptions
+	Query    string `json:"query"`
+	Database string `json:"database"`
+	From     int64  `json:"from"` // epoch milliseconds, defaults to an hour ago
+	To       int64  `json:"to"`   // epoch milliseconds, defaults to now
+}
+
+// VariableValues reads the template variable values from a query response. The text
+// and value come from the columns named text and value (or __text and __value),
+// otherwise from the first column. Values are deduplicated by value.
+func VariableValues(tr *TableResponse, opts VariableOptions) ([]VariableValue, error) {
+	re, err := parseVariableRegex(opts.Regex)
+	if err != nil {
+		return nil, err
+	}
+
+	textColumn, valueColumn := variableColumns(tr)
+	values := []VariableValue{}
+	seen := map[string]bool{}
+	for _, row := range tr.Results {
+		text, ok := variableString(row[textColumn])
+		if !ok {
+			continue
+		}
+		value := text
+		if valueColumn != textColumn {
+			if value, ok = variableString(row[valueColumn]); !ok {
+				continue
+			}
+		}
+
+		if re != nil {
+			if text, value, ok = applyVariableRegex(re, text, value); !ok {
+				continue
+			}
+		}
+
+		if !seen[value] {
+			seen[value] = true
+			values = append(values, VariableValue{Text: text, Value: value})
+		}
+	}
+
+	if err := sortVariableValues(values, opts.Sort); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func variableColumns(tr *TableResponse) (string, string) {
+	text, value := "", ""
+	for _, col := range tr.Columns {
+		switch strings.ToLower(col.Name) {
+		case "text", "__text":
+			text = col.Name
+		case "value", "__value":
+			value = col.Name
+		}
+	}
+	if text == "" && len(tr.Columns) > 0 {
+		text = tr.Columns[0].Name
+	}
+	if value == "" {
+		value = text
+	}
+	return text, value
+}
+
+func variableString(v interface{}) (string, bool) {
+	switch s := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return s, true
+	case json.Number:
+		return s.String(), true
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64), true
+	}
+	return fmt.Sprint(v), true
+}
+
+// parseVariableRegex compiles the regex, which may be written as /pattern/flags.
+func parseVariableRegex(s string) (*regexp.Regexp, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	pattern := s
+	if end := strings.LastIndex(s, "/"); strings.HasPrefix(s, "/") && end > 0 {
+		pattern = s[1:end]
+		flags := s[end+1:]
+		for _, f := range flags {
+			switch f {
+			case 'i', 'm', 's':
+				pattern = fmt.Sprintf("(?%c)", f) + pattern
+			case 'g':
+				// every match is used anyway
+			default:
+				return nil, fmt.Errorf("invalid regex flag %q in %q", f, s)
+			}
+		}
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex %q: %w", s, err)
+	}
+	return re, nil
+}
+
+func applyVariableRegex(re *regexp.Regexp, text string, value string) (string, string, bool) {
+	match := re.FindStringSubmatch(text)
+	if match == nil {
+		return "", "", false
+	}
+
+	named := false
+	for i, name := range re.SubexpNames() {
+		switch name {
+		case "text":
+			text, named = match[i], true
+		case "value":
+			value, named = match[i], true
+		}
+	}
+	if !named && len(match) > 1 {
+		text, value = match[1], match[1]
+	}
+	return text, value, true
+}
+
+func sortVariableValues(values []VariableValue, mode string) error {
+	switch mode {
+	case VariableSortAsc:
+		sort.SliceStable(values, func(i, j int) bool { return values[i].Text < values[j].Text })
+	case VariableSortDesc:
+		sort.SliceStable(values, func(i, j int) bool { return values[i].Text > values[j].Text })
+	case VariableSortNumericAsc, VariableSortNumericDesc:
+		desc := mode == VariableSortNumericDesc
+		sort.SliceStable(values, func(i, j int) bool {
+			a, aErr := strconv.ParseFloat(values[i].Text, 64)
+			b, bErr := strconv.ParseFloat(values[j].Text, 64)
+			switch {
+			case aErr != nil || bErr != nil:
+				// non-numeric values go last
+				return aErr == nil
+			case desc:
+				return a > b
+			}
+			return a < b
+		})
+	case "", VariableSortNone:
+	default:
+		return fmt.Errorf("invalid variable sort %q", mode)
+	}
+	return nil
+}
+
+// VariableFrame returns the values as a frame with a text and a value field.
+func VariableFrame(values []VariableValue) *data.Frame {
+	texts := make([]string, len(values))
+	vals := make([]string, len(values))
+	for i, v := range values {
+		texts[i] = v.Text
+		vals[i] = v.Value
+	}
+	return data.NewFrame("", data.NewField("text", nil, texts), data.NewField("value", nil, vals))
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func variableTable(columns []string, rows ...[]interface{}) *TableResponse {
+	tr := &TableResponse{}
+	for _, c := range columns {
+		tr.Columns = append(tr.Columns, struct {
+			Name string `json:"Name"`
+			Type string `json:"Type"`
+		}{Name: c, Type: "string"})
+	}
+	for _, row := range rows {
+		result := map[string]interface{}{}
+		for i, c := range columns {
+			result[c] = row[i]
+		}
+		tr.Results = append(tr.Results, result)
+	}
+	return tr
+}
+
+func TestVariableValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		table   *TableResponse
+		opts    VariableOptions
+		errorIs assert.ErrorAssertionFunc
+		values  []VariableValue
+	}{
+		{
+			name:    "first column is text and value, deduplicated in query order",
+			table:   variableTable([]string{"Host"}, []interface{}{"web-2"}, []interface{}{"web-1"}, []interface{}{"web-2"}, []interface{}{nil}),
+			errorIs: assert.NoError,
+			values:  []VariableValue{{Text: "web-2", Value: "web-2"}, {Text: "web-1", Value: "web-1"}},
+		},
+		{
+			name:    "sorted",
+			table:   variableTable([]string{"Host"}, []interface{}{"web-2"}, []interface{}{"web-1"}),
+			opts:    VariableOptions{Sort: VariableSortAsc},
+			errorIs: assert.NoError,
+			values:  []VariableValue{{Text: "web-1", Value: "web-1"}, {Text: "web-2", Value: "web-2"}},
+		},
+		{
+			name:    "other columns are ignored",
+			table:   variableTable([]string{"Name", "Id"}, []interface{}{"web", json.Number("1")}, []interface{}{"db", json.Number("2")}),
+			opts:    VariableOptions{Sort: VariableSortNone},
+			errorIs: assert.NoError,
+			values:  []VariableValue{{Text: "web", Value: "web"}, {Text: "db", Value: "db"}},
+		},
+		{
+			name:    "second column named value",
+			table:   variableTable([]string{"Name", "value"}, []interface{}{"web", json.Number("1")}, []interface{}{"db", json.Number("2")}),
+			opts:    VariableOptions{Sort: VariableSortNone},
+			errorIs: assert.NoError,
+			values:  []VariableValue{{Text: "web", Value: "1"}, {Text: "db", Value: "2"}},
+		},
+		{
+			name:    "text and value columns",
+			table:   variableTable([]string{"Other", "__value", "__text"}, []interface{}{"x", "1", "one"}),
+			errorIs: assert.NoError,
+			values:  []VariableValue{{Text: "one", Value: "1"}},
+		},
+		{
+			name:    "regex filter with capture group",
+			table:   variableTable([]string{"Host"}, []interface{}{"web-1.prod"}, []interface{}{"db-1.prod"}, []interface{}{"WEB-2.dev"}),
+			opts:    VariableOptions{Regex: "/^(web-\\d)/i", Sort: VariableSortDesc},
+			errorIs: assert.NoError,
+			values:  []VariableValue{{Text: "web-1", Value: "web-1"}, {Text: "WEB-2", Value: "WEB-2"}},
+		},
+		{
+			name:    "regex with named groups",
+			table:   variableTable([]string{"Host"}, []interface{}{"web-1 (10.0.0.1)"}),
+			opts:    VariableOptions{Regex: `(?P<text>\S+) \((?P<value>[\d.]+)\)`},
+			errorIs: assert.NoError,
+			values:  []VariableValue{{Text: "web-1", Value: "10.0.0.1"}},
+		},
+		{
+			name:    "numeric sort",
+			table:   variableTable([]string{"Port"}, []interface{}{"80"}, []interface{}{"8080"}, []interface{}{"443"}, []interface{}{"any"}),
+			opts:    VariableOptions{Sort: VariableSortNumericAsc},
+			errorIs: assert.NoError,
+			values: []VariableValue{
+				{Text: "80", Value: "80"}, {Text: "443", Value: "443"}, {Text: "8080", Value: "8080"}, {Text: "any", Value: "any"},
+			},
+		},
+		{
+			name:    "invalid regex",
+			table:   variableTable([]string{"Host"}),
+			opts:    VariableOptions{Regex: "("},
+			errorIs: assert.Error,
+		},
+		{
+			name:    "invalid sort",
+			table:   variableTable([]string{"Host"}),
+			opts:    VariableOptions{Sort: "random"},
+			errorIs: assert.Error,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := VariableValues(tt.table, tt.opts)
+			tt.errorIs(t, err)
+			require.Equal(t, tt.values, values)
+		})
+	}
+}
+
+func TestVariableFrame(t *testing.T) {
+	frame := VariableFrame([]VariableValue{{Text: "one", Value: "1"}})
+	require.Equal(t, data.NewFrame("", data.NewField("text", nil, []string{"one"}), data.NewField("value", nil, []string{"1"})), frame)
+}
@@ -1,15 +1,26 @@
 package logship
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
 	"github.com/logsink/grafana-logship-datasource/pkg/logship/models"
 )
 
 func (logship *LogshipBackend) registerRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/schema", logship.getSchema)
+	mux.HandleFunc("/databases", logship.getDatabases)
+	mux.HandleFunc("/tables", logship.getTables)
+	mux.HandleFunc("/tables/", logship.getTableResource)
+	mux.HandleFunc("/functions", logship.getFunctions)
+	mux.HandleFunc("/validate", logship.validateQuery)
+	mux.HandleFunc("/variables", logship.getVariableValues)
 }
 
 func (logship *LogshipBackend) getSchema(rw http.ResponseWriter, req *http.Request) {
@@ -18,57 +29,361 @@ func (logship *LogshipBackend) getSchema(rw http.ResponseWriter, req *http.Reque
 		return
 	}
 
+	entry, err := logship.schema(req.Context(), httpadapter.UserFromContext(req.Context()), req.URL.Query().Get("refresh") == "true")
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Schema query unsuccessful", err)
+		return
+	}
+
+	rw.Header().Set("ETag", entry.ETag)
+	rw.Header().Set("Cache-Control", "no-cache")
+	if etagMatches(req.Header.Get("If-None-Match"), entry.ETag) {
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	writeJSON(rw, entry.Schema)
+}
+
+// fetchSchema queries the schema of all tables, grouped by database.
+func (logship *LogshipBackend) fetchSchema(ctx context.Context) (*models.SchemaResponse, error) {
 	headers := map[string]string{}
-	resp, err := logship.client.KustoRequest(req.Context(), logship.settings.ClusterURL, models.RequestPayload{
+	resp, err := logship.client.KustoRequest(ctx, logship.settings.ClusterURL, models.RequestPayload{
 		Query:       "schema.tables.schema",
 		QuerySource: "grafana-schema",
 	}, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := models.SchemaFromResults(resp.Results, logship.settings.SchemaDatabase(""))
+	backend.Logger.Debug("schema loaded", "databases", len(schema.Databases), "rows", len(resp.Results))
+	return schema, nil
+}
+
+func (logship *LogshipBackend) getDatabases(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		respondWithError(rw, http.StatusMethodNotAllowed, "Invalid method", nil)
+		return
+	}
 
+	entry, err := logship.schema(req.Context(), httpadapter.UserFromContext(req.Context()), false)
 	if err != nil {
 		respondWithError(rw, http.StatusInternalServerError, "Schema query unsuccessful", err)
 		return
 	}
 
-	tables := map[string]models.TableSchema{}
-	for _, t := range resp.Results {
-		tableName, _ := t["TableName"].(string)
-		columnName, _ := t["ColumnName"].(string)
-		columnType, _ := t["ColumnType"].(string)
+	writeJSON(rw, entry.Schema.DatabaseList(logship.settings.SchemaDatabase("")))
+}
 
-		c := models.ColumnSchema{
-			Name: columnName,
-			Type: columnType,
-		}
+// schema returns the cached schema, restricted to the schema mappings when they are used.
+func (logship *LogshipBackend) schema(ctx context.Context, user *backend.User, refresh bool) (schemaEntry, error) {
+	entry, err := logship.schemas.Get(ctx, logship.schemaCacheKey(user), refresh)
+	if err != nil || logship.settings.Mappings == nil {
+		return entry, err
+	}
+
+	entry.Schema = logship.settings.Mappings.FilterSchema(entry.Schema)
+	entry.ETag, err = entry.Schema.ETag()
+	return entry, err
+}
+
+// schemaCacheKey separates the cached schemas per user when queries run on behalf
+// of the user, since each user may see different tables.
+func (logship *LogshipBackend) schemaCacheKey(user *backend.User) string {
+	if logship.settings.AuthType != "oboOAuth" || user == nil {
+		return ""
+	}
+	return user.Login
+}
+
+func (logship *LogshipBackend) getTables(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		respondWithError(rw, http.StatusMethodNotAllowed, "Invalid method", nil)
+		return
+	}
+
+	params, err := models.ParsePageParams(req.URL.Query())
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, "Invalid parameters", err)
+		return
+	}
+
+	entry, err := logship.schema(req.Context(), httpadapter.UserFromContext(req.Context()), false)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Schema query unsuccessful", err)
+		return
+	}
+
+	database := entry.Schema.Database(logship.settings.SchemaDatabase(req.URL.Query().Get("database")))
+	writeJSON(rw, models.ListTables(database.Tables, params))
+}
+
+// getTableResource serves /tables/{name}/columns and /tables/{name}/columns/{col}/values.
+func (logship *LogshipBackend) getTableResource(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		respondWithError(rw, http.StatusMethodNotAllowed, "Invalid method", nil)
+		return
+	}
+
+	segments, err := pathSegments(strings.TrimPrefix(req.URL.EscapedPath(), "/tables/"))
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, "Invalid path", err)
+		return
+	}
+
+	switch {
+	case len(segments) == 2 && segments[1] == "columns":
+		logship.getColumns(rw, req, segments[0])
+	case len(segments) == 4 && segments[1] == "columns" && segments[3] == "values":
+		logship.getColumnValues(rw, req, segments[0], segments[2])
+	default:
+		respondWithError(rw, http.StatusNotFound, "Not found", nil)
+	}
+}
+
+func (logship *LogshipBackend) getColumns(rw http.ResponseWriter, req *http.Request, tableName string) {
+	params, err := models.ParsePageParams(req.URL.Query())
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, "Invalid parameters", err)
+		return
+	}
+
+	table, ok := logship.findTable(rw, req, tableName)
+	if !ok {
+		return
+	}
+
+	writeJSON(rw, models.ListColumns(table, params))
+}
+
+func (logship *LogshipBackend) getColumnValues(rw http.ResponseWriter, req *http.Request, tableName string, columnName string) {
+	params, err := models.ParseValuesParams(req.URL.Query())
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, "Invalid parameters", err)
+		return
+	}
+
+	table, ok := logship.findTable(rw, req, tableName)
+	if !ok {
+		return
+	}
+	if _, ok := table.FindColumn(columnName); !ok {
+		respondWithError(rw, http.StatusNotFound, fmt.Sprintf("Column %q not found in table %q", columnName, tableName), nil)
+		return
+	}
+
+	headers := map[string]string{}
+	resp, err := logship.client.KustoRequest(req.Context(), logship.settings.ClusterURL, models.RequestPayload{
+		Query:       models.ColumnValuesQuery(table, columnName, params),
+		QuerySource: "grafana-values",
+		Database:    logship.settings.DatabaseOrDefault(req.URL.Query().Get("database")),
+	}, headers)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Column values query unsuccessful", err)
+		return
+	}
 
-		val, ok := tables[tableName]
-		if ok {
-			val.Columns = append(val.Columns, c)
-			tables[tableName] = val
-		} else {
-			tables[tableName] = models.TableSchema{
-				Name:    tableName,
-				Columns: []models.ColumnSchema{c},
-			}
+	writeJSON(rw, models.ColumnValueList{
+		Table:  table.Name,
+		Column: columnName,
+		Values: models.ColumnValuesFromResults(resp.Results),
+	})
+}
+
+// findTable looks the table up in the requested database of the cached schema, responding with an error when it is missing.
+func (logship *LogshipBackend) findTable(rw http.ResponseWriter, req *http.Request, name string) (models.TableSchema, bool) {
+	entry, err := logship.schema(req.Context(), httpadapter.UserFromContext(req.Context()), false)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Schema query unsuccessful", err)
+		return models.TableSchema{}, false
+	}
+
+	database := entry.Schema.Database(logship.settings.SchemaDatabase(req.URL.Query().Get("database")))
+	table, ok := models.FindTable(database.Tables, name)
+	if !ok {
+		respondWithError(rw, http.StatusNotFound, fmt.Sprintf("Table %q not found", name), nil)
+		return models.TableSchema{}, false
+	}
+	return table, true
+}
+
+// pathSegments splits an escaped path into its unescaped segments, so that names may contain '/'.
+func pathSegments(escapedPath string) ([]string, error) {
+	segments := strings.Split(strings.Trim(escapedPath, "/"), "/")
+	for i, segment := range segments {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			return nil, err
 		}
+		segments[i] = unescaped
 	}
+	return segments, nil
+}
 
-	backend.Logger.Info("done {%w}", tables)
-	txs := make([]models.TableSchema, 0, len(tables))
-	for _, tx := range tables {
-		txs = append(txs, tx)
+func (logship *LogshipBackend) getFunctions(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "GET" {
+		respondWithError(rw, http.StatusMethodNotAllowed, "Invalid method", nil)
+		return
+	}
+
+	// saved functions are still listed when the function catalog is unavailable
+	stored, err := logship.storedFunctions(req.Context(), httpadapter.UserFromContext(req.Context()), req.URL.Query().Get("database"))
+	if err != nil {
+		backend.Logger.Warn("failed to list stored functions", "error", err)
 	}
 
-	result := models.DatabaseSchemaResponse{
-		Name:   "Default",
-		Tables: txs,
+	writeJSON(rw, models.FunctionSchemas(logship.settings.QueryFunctions, stored))
+}
+
+// storedFunctions lists the functions stored in the database, restricted to the
+// schema mappings when they are used.
+func (logship *LogshipBackend) storedFunctions(ctx context.Context, user *backend.User, database string) ([]models.FunctionSchema, error) {
+	entry, err := logship.schemas.Get(ctx, logship.schemaCacheKey(user), false)
+	if err != nil {
+		return nil, err
+	}
+	database = logship.settings.SchemaDatabase(database)
+	functions, err := entry.Functions.Get(ctx, database, logship.fetchStoredFunctions)
+	if err != nil {
+		return nil, err
+	}
+	if logship.settings.Mappings != nil {
+		functions = logship.settings.Mappings.FilterFunctions(database, functions)
+	}
+	return functions, nil
+}
+
+// fetchStoredFunctions lists every function stored in the database. Use the
+// Functions of a schema entry, which caches them along with the schema.
+func (logship *LogshipBackend) fetchStoredFunctions(ctx context.Context, database string) ([]models.FunctionSchema, error) {
+	headers := map[string]string{}
+	resp, err := logship.client.KustoRequest(ctx, logship.settings.ClusterURL, models.RequestPayload{
+		Query:       models.FunctionCatalogQuery,
+		QuerySource: "grafana-functions",
+		Database:    database,
+	}, headers)
+	if err != nil {
+		return nil, err
+	}
+	return models.StoredFunctionSchemas(resp.Results, database)[database], nil
+}
+
+func (logship *LogshipBackend) validateQuery(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		respondWithError(rw, http.StatusMethodNotAllowed, "Invalid method", nil)
+		return
+	}
+
+	var vr models.ValidationRequest
+	if err := json.NewDecoder(req.Body).Decode(&vr); err != nil {
+		respondWithError(rw, http.StatusBadRequest, "Malformed validation request", err)
+		return
+	}
+
+	tr := models.TimeRangeOrDefault(vr.From, vr.To)
+	md := models.NewMacroData(&tr, vr.IntervalMS)
+	interpolated, err := models.ExpandFunctions(vr.Query, logship.settings.QueryFunctions)
+	if err == nil {
+		interpolated, err = md.Interpolate(models.InterpolateEmptyResults(interpolated))
+	}
+	if err != nil {
+		writeJSON(rw, models.ValidationResponse{
+			Valid:       false,
+			Query:       vr.Query,
+			Diagnostics: []models.Diagnostic{models.QueryDiagnostic(vr.Query, err.Error())},
+		})
+		return
 	}
 
+	qm := models.QueryModel{Query: interpolated, Database: vr.Database}
+	if err := logship.enforceSchemaMapping(req.Context(), qm, httpadapter.UserFromContext(req.Context())); err != nil {
+		status, _ := models.ClassifyError(err)
+		respondWithError(rw, int(status), "Query rejected", err)
+		return
+	}
+
+	headers := map[string]string{}
+	errResp, err := logship.client.ValidateRequest(req.Context(), logship.settings.ClusterURL, models.RequestPayload{
+		Query:       models.ValidationQuery(interpolated),
+		QuerySource: "grafana-validate",
+		Database:    logship.settings.DatabaseOrDefault(vr.Database),
+	}, headers)
+	if err != nil {
+		respondWithError(rw, http.StatusInternalServerError, "Validation query unsuccessful", err)
+		return
+	}
+
+	writeJSON(rw, models.NewValidationResponse(vr.Query, interpolated, errResp))
+}
+
+// getVariableValues runs a template variable query and returns its values without
+// converting the response into data frames.
+func (logship *LogshipBackend) getVariableValues(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		respondWithError(rw, http.StatusMethodNotAllowed, "Invalid method", nil)
+		return
+	}
+
+	var vr models.VariableRequest
+	if err := json.NewDecoder(req.Body).Decode(&vr); err != nil {
+		respondWithError(rw, http.StatusBadRequest, "Malformed variable request", err)
+		return
+	}
+
+	tr := models.TimeRangeOrDefault(vr.From, vr.To)
+	qm := models.QueryModel{
+		QueryType:       models.QueryTypeVariable,
+		Query:           vr.Query,
+		Database:        vr.Database,
+		QuerySource:     "variable",
+		MacroData:       models.NewMacroData(&tr, 0),
+		VariableOptions: vr.VariableOptions,
+	}
+	if err := qm.ExpandFunctions(logship.settings.QueryFunctions); err != nil {
+		respondWithError(rw, http.StatusBadRequest, "Variable query interpolation failed", err)
+		return
+	}
+	if err := qm.Interpolate(); err != nil {
+		respondWithError(rw, http.StatusBadRequest, "Variable query interpolation failed", err)
+		return
+	}
+	user := httpadapter.UserFromContext(req.Context())
+	if err := logship.enforceSchemaMapping(req.Context(), qm, user); err != nil {
+		status, _ := models.ClassifyError(err)
+		respondWithError(rw, int(status), "Variable query rejected", err)
+		return
+	}
+
+	headers := map[string]string{}
+	if logship.settings.EnableUserTracking && user != nil {
+		headers["x-logship-user-id"] = user.Login
+	}
+	resp, err := logship.client.KustoRequest(req.Context(), logship.settings.ClusterURL, models.RequestPayload{
+		Query:       qm.Query,
+		QuerySource: qm.QuerySource,
+		Database:    logship.settings.DatabaseOrDefault(qm.Database),
+	}, headers)
+	if err != nil {
+		status, _ := models.ClassifyError(err)
+		respondWithError(rw, int(status), "Variable query unsuccessful", err)
+		return
+	}
+
+	values, err := models.VariableValues(resp, qm.VariableOptions)
+	if err != nil {
+		respondWithError(rw, http.StatusBadRequest, "Invalid variable options", err)
+		return
+	}
+	writeJSON(rw, values)
+}
+
+func writeJSON(rw http.ResponseWriter, v interface{}) {
 	rw.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(rw).Encode(result)
+	err := json.NewEncoder(rw).Encode(v)
 	if err != nil {
 		respondWithError(rw, http.StatusInternalServerError, "Internal server error", err)
 	}
-
 }
 
 func respondWithError(rw http.ResponseWriter, code int, message string, err error) {
@@ -0,0 +1,193 @@
+package logship
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/logsink/grafana-logship-datasource/pkg/logship/metrics"
+	"github.com/logsink/grafana-logship-datasource/pkg/logship/models"
+)
+
+type schemaFetcher = func(ctx context.Context) (*models.SchemaResponse, error)
+
+type functionFetcher = func(ctx context.Context, database string) ([]models.FunctionSchema, error)
+
+// schemaEntry is a cached schema along with its entity tag and the stored functions
+// of its databases.
+type schemaEntry struct {
+	Schema    *models.SchemaResponse
+	ETag      string
+	Functions *functionCatalog
+	fetched   time.Time
+}
+
+// schemaCache caches schemas for a TTL. Once an entry is stale it is still served
+// while a single background refresh replaces it. Concurrent loads of the same key
+// share a single fetch.
+type schemaCache struct {
+	uid     string
+	ttl     time.Duration
+	fetch   schemaFetcher
+	now     func() time.Time
+	loading singleflight.Group
+
+	mu         sync.Mutex
+	entries    map[string]schemaEntry
+	refreshing map[string]bool
+}
+
+func newSchemaCache(uid string, ttl time.Duration, fetch schemaFetcher) *schemaCache {
+	return &schemaCache{
+		uid:        uid,
+		ttl:        ttl,
+		fetch:      fetch,
+		now:        time.Now,
+		entries:    map[string]schemaEntry{},
+		refreshing: map[string]bool{},
+	}
+}
+
+// Get returns the cached schema for key, fetching it when it is missing, when
+// caching is disabled or when refresh is set.
+func (c *schemaCache) Get(ctx context.Context, key string, refresh bool) (schemaEntry, error) {
+	if c.ttl > 0 && !refresh {
+		c.mu.Lock()
+		entry, ok := c.entries[key]
+		if ok {
+			if c.now().Sub(entry.fetched) >= c.ttl && !c.refreshing[key] {
+				c.refreshing[key] = true
+				go c.refresh(detachedContext{ctx}, key)
+			}
+			c.mu.Unlock()
+			metrics.CacheHit(c.uid, "schema")
+			return entry, nil
+		}
+		c.mu.Unlock()
+	}
+
+	metrics.CacheMiss(c.uid, "schema")
+	return c.load(ctx, key)
+}
+
+func (c *schemaCache) load(ctx context.Context, key string) (schemaEntry, error) {
+	entry, err := shared(ctx, &c.loading, key, func(ctx context.Context) (interface{}, error) {
+		return c.fetchEntry(ctx, key)
+	})
+	if err != nil {
+		return schemaEntry{}, err
+	}
+	return entry.(schemaEntry), nil
+}
+
+func (c *schemaCache) fetchEntry(ctx context.Context, key string) (schemaEntry, error) {
+	schema, err := c.fetch(ctx)
+	if err != nil {
+		return schemaEntry{}, err
+	}
+	etag, err := schema.ETag()
+	if err != nil {
+		return schemaEntry{}, err
+	}
+
+	entry := schemaEntry{Schema: schema, ETag: etag, Functions: newFunctionCatalog(), fetched: c.now()}
+	if c.ttl > 0 {
+		c.mu.Lock()
+		c.entries[key] = entry
+		c.mu.Unlock()
+	}
+	return entry, nil
+}
+
+func (c *schemaCache) refresh(ctx context.Context, key string) {
+	defer func() {
+		c.mu.Lock()
+		delete(c.refreshing, key)
+		c.mu.Unlock()
+	}()
+
+	if _, err := c.load(ctx, key); err != nil {
+		backend.Logger.Warn("failed to refresh schema, serving the stale schema", "error", err)
+	}
+}
+
+// functionCatalog caches the stored functions of each database of a schema entry,
+// so they are refreshed along with the schema. Each database is fetched once, on
+// first use.
+type functionCatalog struct {
+	loading singleflight.Group
+
+	mu        sync.Mutex
+	functions map[string][]models.FunctionSchema
+}
+
+func newFunctionCatalog() *functionCatalog {
+	return &functionCatalog{functions: map[string][]models.FunctionSchema{}}
+}
+
+// Get returns the stored functions of the database, fetching them when they are missing.
+func (c *functionCatalog) Get(ctx context.Context, database string, fetch functionFetcher) ([]models.FunctionSchema, error) {
+	c.mu.Lock()
+	functions, ok := c.functions[database]
+	c.mu.Unlock()
+	if ok {
+		return functions, nil
+	}
+
+	loaded, err := shared(ctx, &c.loading, database, func(ctx context.Context) (interface{}, error) {
+		functions, err := fetch(ctx, database)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		c.functions[database] = functions
+		c.mu.Unlock()
+		return functions, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loaded.([]models.FunctionSchema), nil
+}
+
+// shared runs fn once for concurrent callers of the same key. fn runs on a detached
+// context, so that a cancelled caller only stops waiting rather than failing the
+// fetch for every other caller.
+func shared(ctx context.Context, group *singleflight.Group, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	detached := detachedContext{ctx}
+	ch := group.DoChan(key, func() (interface{}, error) {
+		return fn(detached)
+	})
+	select {
+	case res := <-ch:
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// detachedContext keeps the values of a request context, e.g. the user's OAuth
+// token, without its cancellation so background work outlives the request.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+// etagMatches reports whether the If-None-Match header matches the entity tag.
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package logship
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/logsink/grafana-logship-datasource/pkg/logship/models"
+)
+
+func TestSchemaCache(t *testing.T) {
+	newCache := func(ttl time.Duration) (*schemaCache, *int, chan struct{}) {
+		fetches := 0
+		fetched := make(chan struct{}, 10)
+		cache := newSchemaCache("uid", ttl, func(ctx context.Context) (*models.SchemaResponse, error) {
+			fetches++
+			defer func() { fetched <- struct{}{} }()
+			return &models.SchemaResponse{Databases: []models.DatabaseSchemaResponse{{Name: "Default", Tables: []models.TableSchema{{Name: "T", Columns: make([]models.ColumnSchema, fetches)}}}}}, nil
+		})
+		return cache, &fetches, fetched
+	}
+
+	t.Run("serves the cached schema within the TTL", func(t *testing.T) {
+		cache, fetches, _ := newCache(time.Minute)
+		first, err := cache.Get(context.Background(), "", false)
+		require.NoError(t, err)
+		second, err := cache.Get(context.Background(), "", false)
+		require.NoError(t, err)
+
+		require.Equal(t, 1, *fetches)
+		require.Equal(t, first.ETag, second.ETag)
+		require.NotEmpty(t, first.ETag)
+	})
+
+	t.Run("refresh bypasses the cache", func(t *testing.T) {
+		cache, fetches, _ := newCache(time.Minute)
+		first, err := cache.Get(context.Background(), "", false)
+		require.NoError(t, err)
+		second, err := cache.Get(context.Background(), "", true)
+		require.NoError(t, err)
+
+		require.Equal(t, 2, *fetches)
+		require.NotEqual(t, first.ETag, second.ETag)
+	})
+
+	t.Run("keys are cached separately", func(t *testing.T) {
+		cache, fetches, _ := newCache(time.Minute)
+		_, err := cache.Get(context.Background(), "alice", false)
+		require.NoError(t, err)
+		_, err = cache.Get(context.Background(), "bob", false)
+		require.NoError(t, err)
+
+		require.Equal(t, 2, *fetches)
+	})
+
+	t.Run("zero TTL disables the cache", func(t *testing.T) {
+		cache, fetches, _ := newCache(0)
+		_, err := cache.Get(context.Background(), "", false)
+		require.NoError(t, err)
+		_, err = cache.Get(context.Background(), "", false)
+		require.NoError(t, err)
+
+		require.Equal(t, 2, *fetches)
+	})
+
+	t.Run("stale schema is served while it is refreshed in the background", func(t *testing.T) {
+		cache, _, fetched := newCache(time.Minute)
+		now := time.Now()
+		cache.now = func() time.Time { return now }
+
+		first, err := cache.Get(context.Background(), "", false)
+		require.NoError(t, err)
+		<-fetched
+
+		now = now.Add(2 * time.Minute)
+		stale, err := cache.Get(context.Background(), "", false)
+		require.NoError(t, err)
+		require.Equal(t, first.ETag, stale.ETag)
+
+		select {
+		case <-fetched:
+		case <-time.After(time.Second):
+			t.Fatal("schema was not refreshed")
+		}
+		require.Eventually(t, func() bool {
+			refreshed, err := cache.Get(context.Background(), "", false)
+			return err == nil && refreshed.ETag != first.ETag
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("concurrent misses share a single fetch", func(t *testing.T) {
+		var fetches int32
+		release := make(chan struct{})
+		cache := newSchemaCache("uid", time.Minute, func(ctx context.Context) (*models.SchemaResponse, error) {
+			atomic.AddInt32(&fetches, 1)
+			<-release
+			return &models.SchemaResponse{}, nil
+		})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := cache.Get(context.Background(), "", false)
+				require.NoError(t, err)
+			}()
+		}
+		require.Eventually(t, func() bool { return atomic.LoadInt32(&fetches) == 1 }, time.Second, time.Millisecond)
+		// give the other requests time to join the fetch in flight
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		require.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+	})
+
+	t.Run("a cancelled request does not fail the fetch it shares", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		cache := newSchemaCache("uid", time.Minute, func(ctx context.Context) (*models.SchemaResponse, error) {
+			close(started)
+			<-release
+			return &models.SchemaResponse{}, ctx.Err()
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancelled := make(chan error)
+		go func() {
+			_, err := cache.Get(ctx, "", false)
+			cancelled <- err
+		}()
+		<-started
+
+		waiting := make(chan error)
+		go func() {
+			_, err := cache.Get(context.Background(), "", false)
+			waiting <- err
+		}()
+		// give the second request time to join the fetch in flight
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+		require.ErrorIs(t, <-cancelled, context.Canceled)
+
+		close(release)
+		require.NoError(t, <-waiting)
+	})
+
+	t.Run("fetch errors are returned and not cached", func(t *testing.T) {
+		errSchema := errors.New("schema unavailable")
+		cache := newSchemaCache("uid", time.Minute, func(ctx context.Context) (*models.SchemaResponse, error) {
+			return nil, errSchema
+		})
+		_, err := cache.Get(context.Background(), "", false)
+		require.ErrorIs(t, err, errSchema)
+		require.Empty(t, cache.entries)
+	})
+}
+
+func TestFunctionCatalog(t *testing.T) {
+	fetched := []string{}
+	fetch := func(ctx context.Context, database string) ([]models.FunctionSchema, error) {
+		fetched = append(fetched, database)
+		if database == "broken" {
+			return nil, errors.New("catalog unavailable")
+		}
+		return []models.FunctionSchema{{Name: database + "Fn"}}, nil
+	}
+	catalog := newFunctionCatalog()
+
+	for i := 0; i < 2; i++ {
+		functions, err := catalog.Get(context.Background(), "Default", fetch)
+		require.NoError(t, err)
+		require.Equal(t, []models.FunctionSchema{{Name: "DefaultFn"}}, functions)
+	}
+	functions, err := catalog.Get(context.Background(), "archive", fetch)
+	require.NoError(t, err)
+	require.Equal(t, []models.FunctionSchema{{Name: "archiveFn"}}, functions)
+
+	_, err = catalog.Get(context.Background(), "broken", fetch)
+	require.Error(t, err)
+	_, err = catalog.Get(context.Background(), "broken", fetch)
+	require.Error(t, err)
+
+	require.Equal(t, []string{"Default", "archive", "broken", "broken"}, fetched)
+}
+
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		matches     bool
+	}{
+		{name: "no header", ifNoneMatch: "", matches: false},
+		{name: "same tag", ifNoneMatch: `"abc"`, matches: true},
+		{name: "weak tag", ifNoneMatch: `W/"abc"`, matches: true},
+		{name: "tag in list", ifNoneMatch: `"xyz", "abc"`, matches: true},
+		{name: "wildcard", ifNoneMatch: "*", matches: true},
+		{name: "other tag", ifNoneMatch: `"xyz"`, matches: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.matches, etagMatches(tt.ifNoneMatch, `"abc"`))
+		})
+	}
+}
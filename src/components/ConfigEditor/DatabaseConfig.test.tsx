@@ -8,7 +8,7 @@ import { openMenu } from 'react-select-event';
 
 const jestPut = jest.fn().mockResolvedValue({ datasource: {} });
 const mockDS = mockDatasource();
-mockDS.getSchema = jest.fn().mockResolvedValue(createMockSchema());
+mockDS.getSchemas = jest.fn().mockResolvedValue({ databases: [createMockSchema()] });
 
 jest.mock('@grafana/runtime', () => ({
   ...jest.requireActual('@grafana/runtime'),
@@ -57,7 +57,7 @@ describe('DatabaseConfig', () => {
     expect(refreshButton).toBeEnabled();
     refreshButton.click();
     expect(jestPut).toHaveBeenCalled();
-    expect(mockDS.getSchema).toHaveBeenCalled();
+    expect(mockDS.getSchemas).toHaveBeenCalled();
     const sel = screen.getByLabelText('choose default database');
     openMenu(sel);
     await waitFor(() => expect(screen.getByText('testdb')).toBeInTheDocument());
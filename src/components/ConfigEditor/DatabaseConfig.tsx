@@ -163,6 +163,22 @@ const DatabaseConfig: React.FC<DatabaseConfigProps> = (props: DatabaseConfigProp
         />
       </InlineField>
 
+      {jsonData.useSchemaMapping && (
+        <InlineField
+          label="Enforce mappings"
+          labelWidth={LABEL_WIDTH}
+          tooltip="Reject queries that reference tables which are not mapped below."
+        >
+          <InlineSwitch
+            id="logship-enforce-schema-mapping"
+            value={jsonData.enforceSchemaMapping}
+            onChange={(ev: React.ChangeEvent<HTMLInputElement>) =>
+              updateJsonData('enforceSchemaMapping', ev.target.checked)
+            }
+          />
+        </InlineField>
+      )}
+
       {jsonData.useSchemaMapping && (
         <InlineField label="Schema mappings" labelWidth={LABEL_WIDTH}>
           <VerticalGroup spacing="xs">
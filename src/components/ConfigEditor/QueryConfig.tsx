@@ -54,6 +54,33 @@ const QueryConfig: React.FC<QueryConfigProps> = ({ options, updateJsonData }) =>
           onChange={(ev: React.ChangeEvent<HTMLInputElement>) => updateJsonData('cacheMaxAge', ev.target.value)}
         />
       </InlineField>
+
+      <InlineField
+        label="Schema cache TTL"
+        labelWidth={LABEL_WIDTH}
+        tooltip="How long the database schema is cached before it is refreshed in the background. Set to 0s to disable the schema cache. When schema mappings are enforced the schema is cached for at least 30s."
+      >
+        <Input
+          value={jsonData.schemaCacheTtl}
+          id="logship-schema-cache-ttl"
+          placeholder="5m"
+          width={18}
+          onChange={(ev: React.ChangeEvent<HTMLInputElement>) => updateJsonData('schemaCacheTtl', ev.target.value)}
+        />
+      </InlineField>
+
+      <InlineField
+        label="Show stack traces"
+        labelWidth={LABEL_WIDTH}
+        tooltip="Add the stack trace Logship returns for a failed query to the error shown in the panel."
+      >
+        <InlineSwitch
+          value={jsonData.showStackTrace}
+          id="logship-show-stack-trace"
+          transparent={false}
+          onChange={(ev: React.ChangeEvent<HTMLInputElement>) => updateJsonData('showStackTrace', ev.target.checked)}
+        />
+      </InlineField>
     </FieldSet>
   );
 };
@@ -0,0 +1,93 @@
+import { DataSourcePluginOptionsEditorProps } from '@grafana/data';
+import { Button, FieldSet, HorizontalGroup, InlineField, Input, TextArea, VerticalGroup } from '@grafana/ui';
+import React, { useCallback, useMemo } from 'react';
+import { LogshipDataSourceOptions, LogshipDataSourceSecureOptions, QueryFunction } from 'types';
+
+interface QueryFunctionsConfigProps
+  extends DataSourcePluginOptionsEditorProps<LogshipDataSourceOptions, LogshipDataSourceSecureOptions> {
+  updateJsonData: <T extends keyof LogshipDataSourceOptions>(fieldName: T, value: LogshipDataSourceOptions[T]) => void;
+}
+
+const LABEL_WIDTH = 21;
+
+const QueryFunctionsConfig: React.FC<QueryFunctionsConfigProps> = ({ options, updateJsonData }) => {
+  const functions = useMemo(() => options.jsonData.queryFunctions ?? [], [options.jsonData.queryFunctions]);
+
+  const handleAddFunction = useCallback(() => {
+    updateJsonData('queryFunctions', [...functions, { name: '', parameters: [], body: '' }]);
+  }, [functions, updateJsonData]);
+
+  const handleFunctionChange = (index: number, change: Partial<QueryFunction>) => {
+    const newFunctions = [...functions];
+    newFunctions[index] = { ...newFunctions[index], ...change };
+    updateJsonData('queryFunctions', newFunctions);
+  };
+
+  const handleRemoveFunction = (index: number) => {
+    const newFunctions = [...functions];
+    newFunctions.splice(index, 1);
+    updateJsonData('queryFunctions', newFunctions);
+  };
+
+  return (
+    <FieldSet label="Query functions">
+      <InlineField
+        label="Functions"
+        labelWidth={LABEL_WIDTH}
+        tooltip="Query fragments that queries call with $__fn(name, arguments...). The body refers to the parameters as {parameter}."
+      >
+        <VerticalGroup spacing="xs">
+          {functions.map((fn, index) => (
+            <VerticalGroup spacing="xs" key={index}>
+              <HorizontalGroup spacing="xs">
+                <Input
+                  placeholder="Name"
+                  width={20}
+                  value={fn.name}
+                  onChange={(ev: React.ChangeEvent<HTMLInputElement>) =>
+                    handleFunctionChange(index, { name: ev.target.value })
+                  }
+                />
+                <Input
+                  placeholder="Parameters, comma separated"
+                  width={38}
+                  value={(fn.parameters ?? []).join(', ')}
+                  onChange={(ev: React.ChangeEvent<HTMLInputElement>) =>
+                    handleFunctionChange(index, { parameters: ev.target.value.split(',').map((p) => p.trim()) })
+                  }
+                  // empty parameters are kept while typing and dropped once the field is left
+                  onBlur={() =>
+                    handleFunctionChange(index, { parameters: (fn.parameters ?? []).filter((p) => p !== '') })
+                  }
+                />
+                <Button
+                  variant="secondary"
+                  size="md"
+                  icon="trash-alt"
+                  aria-label="Remove"
+                  type="button"
+                  onClick={() => handleRemoveFunction(index)}
+                ></Button>
+              </HorizontalGroup>
+              <TextArea
+                placeholder="Requests | where Host == {host}"
+                rows={3}
+                cols={60}
+                value={fn.body}
+                onChange={(ev: React.ChangeEvent<HTMLTextAreaElement>) =>
+                  handleFunctionChange(index, { body: ev.target.value })
+                }
+              />
+            </VerticalGroup>
+          ))}
+
+          <Button variant="secondary" size="md" onClick={handleAddFunction} type="button">
+            Add function
+          </Button>
+        </VerticalGroup>
+      </InlineField>
+    </FieldSet>
+  );
+};
+
+export default QueryFunctionsConfig;
@@ -0,0 +1,143 @@
+import { DataSourcePluginOptionsEditorProps, SelectableValue } from '@grafana/data';
+import {
+  Button,
+  FieldSet,
+  HorizontalGroup,
+  Icon,
+  InlineField,
+  InlineLabel,
+  InlineSwitch,
+  Input,
+  Select,
+  VerticalGroup,
+} from '@grafana/ui';
+import React, { useState } from 'react';
+import { LogshipDataSourceOptions, LogshipDataSourceSecureOptions } from 'types';
+
+interface TypeMappingsConfigProps
+  extends DataSourcePluginOptionsEditorProps<LogshipDataSourceOptions, LogshipDataSourceSecureOptions> {
+  updateJsonData: <T extends keyof LogshipDataSourceOptions>(fieldName: T, value: LogshipDataSourceOptions[T]) => void;
+}
+
+interface TypeMapping {
+  from: string;
+  to?: string;
+}
+
+// COLUMN_TYPES are the Logship types the backend has a converter for.
+const COLUMN_TYPES: Array<SelectableValue<string>> = [
+  'Boolean',
+  'DateTime',
+  'Decimal',
+  'Dynamic',
+  'Float32',
+  'Float64',
+  'Guid',
+  'Int128',
+  'Int32',
+  'Int64',
+  'String',
+  'TimeSpan',
+  'UInt32',
+  'UInt64',
+  'real',
+].map((type) => ({ label: type, value: type }));
+
+const LABEL_WIDTH = 21;
+
+const TypeMappingsConfig: React.FC<TypeMappingsConfigProps> = ({ options, updateJsonData }) => {
+  // rows are kept apart from jsonData so that rows without a type yet are not lost
+  const [mappings, setMappings] = useState<TypeMapping[]>(() =>
+    Object.entries(options.jsonData.typeMappings ?? {}).map(([from, to]) => ({ from, to }))
+  );
+
+  const updateMappings = (newMappings: TypeMapping[]) => {
+    setMappings(newMappings);
+    const typeMappings: Record<string, string> = {};
+    for (const { from, to } of newMappings) {
+      if (from.trim() && to) {
+        typeMappings[from.trim()] = to;
+      }
+    }
+    updateJsonData('typeMappings', typeMappings);
+  };
+
+  const handleMappingChange = (index: number, change: Partial<TypeMapping>) => {
+    const newMappings = [...mappings];
+    newMappings[index] = { ...newMappings[index], ...change };
+    updateMappings(newMappings);
+  };
+
+  const handleRemoveMapping = (index: number) => {
+    const newMappings = [...mappings];
+    newMappings.splice(index, 1);
+    updateMappings(newMappings);
+  };
+
+  return (
+    <FieldSet label="Column types">
+      <InlineField
+        label="Int128 as string"
+        labelWidth={LABEL_WIDTH}
+        tooltip="Return Int128 columns as strings with every digit. By default they are numbers, which lose precision above 2^53."
+      >
+        <InlineSwitch
+          value={options.jsonData.int128AsString}
+          id="logship-int128-as-string"
+          transparent={false}
+          onChange={(ev: React.ChangeEvent<HTMLInputElement>) => updateJsonData('int128AsString', ev.target.checked)}
+        />
+      </InlineField>
+
+      <InlineField
+        label="Type mappings"
+        labelWidth={LABEL_WIDTH}
+        tooltip="Convert the columns of new or unsupported Logship types like the columns of a supported type, e.g. UInt128 as Int128. Columns of other unsupported types are returned as strings."
+      >
+        <VerticalGroup spacing="xs">
+          {mappings.map((mapping, index) => (
+            <HorizontalGroup spacing="xs" key={index}>
+              <Input
+                placeholder="Logship type"
+                width={20}
+                value={mapping.from}
+                onChange={(ev: React.ChangeEvent<HTMLInputElement>) =>
+                  handleMappingChange(index, { from: ev.target.value })
+                }
+              />
+              <InlineLabel width="auto">
+                <Icon name="arrow-right" />
+              </InlineLabel>
+              <Select
+                width={20}
+                placeholder="Supported type"
+                options={COLUMN_TYPES}
+                value={mapping.to}
+                onChange={(change: SelectableValue<string>) => handleMappingChange(index, { to: change.value })}
+              />
+              <Button
+                variant="secondary"
+                size="md"
+                icon="trash-alt"
+                aria-label="Remove"
+                type="button"
+                onClick={() => handleRemoveMapping(index)}
+              ></Button>
+            </HorizontalGroup>
+          ))}
+
+          <Button
+            variant="secondary"
+            size="md"
+            onClick={() => setMappings([...mappings, { from: '' }])}
+            type="button"
+          >
+            Add type mapping
+          </Button>
+        </VerticalGroup>
+      </InlineField>
+    </FieldSet>
+  );
+};
+
+export default TypeMappingsConfig;
@@ -3,8 +3,11 @@ import { DataSourcePluginOptionsEditorProps } from '@grafana/data';
 import ConfigHelp from './ConfigHelp';
 import { LogshipDataSourceOptions, LogshipDataSourceSecureOptions } from 'types';
 import ConnectionConfig from './ConnectionConfig';
-// import QueryConfig from './QueryConfig';
+import DatabaseConfig from './DatabaseConfig';
+import QueryConfig from './QueryConfig';
 import TrackingConfig from './TrackingConfig';
+import TypeMappingsConfig from './TypeMappingsConfig';
+import QueryFunctionsConfig from './QueryFunctionsConfig';
 import AuthenticationConfig from './AuthenticationConfig';
 
 export interface ConfigEditorProps
@@ -34,7 +37,10 @@ const ConfigEditor: React.FC<ConfigEditorProps> = (props) => {
       <ConfigHelp />
       <ConnectionConfig options={options} onOptionsChange={onOptionsChange} updateJsonData={updateJsonData} />
       <AuthenticationConfig options={options} userIdentityEnabled={false} onOptionsChange={onOptionsChange} updateJsonData={updateJsonData} />
-      {/* <QueryConfig options={options} onOptionsChange={onOptionsChange} updateJsonData={updateJsonData} /> */}
+      <DatabaseConfig options={options} onOptionsChange={onOptionsChange} updateJsonData={updateJsonData} />
+      <QueryConfig options={options} onOptionsChange={onOptionsChange} updateJsonData={updateJsonData} />
+      <QueryFunctionsConfig options={options} onOptionsChange={onOptionsChange} updateJsonData={updateJsonData} />
+      <TypeMappingsConfig options={options} onOptionsChange={onOptionsChange} updateJsonData={updateJsonData} />
       <TrackingConfig options={options} onOptionsChange={onOptionsChange} updateJsonData={updateJsonData} />
     </>
   );
@@ -13,8 +13,8 @@ export async function refreshSchema(datasource: LogshipDataSource): Promise<Sche
   const databases: Array<{ label: string; value: string }> = [];
   const schemaMappingOptions: SchemaMappingOption[] = [];
 
-  const schema = await datasource.getSchema();
-  for (const database of [schema]) {
+  const schema = await datasource.getSchemas();
+  for (const database of schema.databases) {
     databases.push({
       label: database.name,
       value: database.name,
@@ -0,0 +1,16 @@
+import { QueryEditorProps } from '@grafana/data';
+import React from 'react';
+import { KustoQuery, LogshipDataSourceOptions } from 'types';
+import { LogshipDataSource } from '../../datasource';
+import { QueryEditor } from './QueryEditor';
+import { ANNOTATION_OPTIONS, QueryOptionFields } from './QueryOptions';
+
+type Props = QueryEditorProps<LogshipDataSource, KustoQuery, LogshipDataSourceOptions>;
+
+// AnnotationQueryEditor is the query editor with the columns annotations are read from.
+export const AnnotationQueryEditor: React.FC<Props> = (props) => (
+  <>
+    <QueryEditor {...props} functionQueries={false} />
+    <QueryOptionFields query={props.query} options={ANNOTATION_OPTIONS} onChange={props.onChange} />
+  </>
+);
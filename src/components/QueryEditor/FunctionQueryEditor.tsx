@@ -0,0 +1,84 @@
+import { SelectableValue } from '@grafana/data';
+import { Alert, InlineField, InlineFieldRow, Input, Select } from '@grafana/ui';
+import { LogshipDataSource } from 'datasource';
+import React from 'react';
+import { useAsync } from 'react-use';
+import { FunctionParameter, KustoQuery, LogshipFunctionSchema } from 'types';
+
+interface FunctionQueryEditorProps {
+  datasource: LogshipDataSource;
+  query: KustoQuery;
+  onChange: (query: KustoQuery) => void;
+}
+
+const LABEL_WIDTH = 18;
+
+// FunctionQueryEditor edits queries that call a function stored in the database
+// with a value for each of its parameters. The backend formats the values as
+// literals of the parameter types.
+export const FunctionQueryEditor = ({ datasource, query, onChange }: FunctionQueryEditorProps) => {
+  const functions = useAsync(() => datasource.getStoredFunctions(query.database), [datasource.id, query.database]);
+  const selected = functions.value?.find((fn) => fn.Name === query.function);
+  const options: Array<SelectableValue<string>> = (functions.value ?? []).map((fn) => ({
+    label: fn.Name,
+    value: fn.Name,
+    description: fn.DocString,
+  }));
+
+  const onFunctionChange = (name?: string) => {
+    const fn = functions.value?.find((f) => f.Name === name);
+    onChange({ ...query, function: name, parameters: fn ? functionParameters(fn, query.parameters) : [] });
+  };
+
+  const onParameterChange = (index: number, value: string) => {
+    const parameters = [...(query.parameters ?? [])];
+    parameters[index] = { ...parameters[index], value: value === '' ? undefined : value };
+    onChange({ ...query, parameters });
+  };
+
+  return (
+    <>
+      {functions.error && <Alert title="Could not load the stored functions">{String(functions.error)}</Alert>}
+      <InlineFieldRow>
+        <InlineField label="Function" labelWidth={LABEL_WIDTH} tooltip="Function stored in the database.">
+          <Select
+            inputId="logship-function"
+            width={40}
+            isLoading={functions.loading}
+            options={options}
+            value={query.function}
+            onChange={(change: SelectableValue<string>) => onFunctionChange(change.value)}
+          />
+        </InlineField>
+      </InlineFieldRow>
+      {selected && (
+        <InlineFieldRow>
+          {(query.parameters ?? []).map((parameter, index) => (
+            <InlineField
+              key={parameter.name}
+              label={parameter.name}
+              labelWidth={LABEL_WIDTH}
+              tooltip={`Value of type ${parameter.type}. Left empty, the parameter is null.`}
+            >
+              <Input
+                id={`logship-parameter-${parameter.name}`}
+                width={20}
+                placeholder={parameter.type}
+                value={parameter.value === undefined || parameter.value === null ? '' : String(parameter.value)}
+                onChange={(ev: React.ChangeEvent<HTMLInputElement>) => onParameterChange(index, ev.target.value)}
+              />
+            </InlineField>
+          ))}
+        </InlineFieldRow>
+      )}
+    </>
+  );
+};
+
+// functionParameters returns the parameters of the function, keeping the values
+// of parameters of the same name and type.
+const functionParameters = (fn: LogshipFunctionSchema, previous: FunctionParameter[] = []): FunctionParameter[] =>
+  fn.InputParameters.map(({ name, type }) => {
+    const kept = previous.find((p) => p.name === name && p.type === type);
+    return { name, type, value: kept?.value };
+  });
@@ -17,6 +17,9 @@ export interface InlineTableSelectProps {
 const EDITOR_FORMATS: Array<SelectableValue<QueryResultFormat>> = [
   { label: 'Table', value: 'table' },
   { label: 'Time Series', value: 'time_series' },
+  { label: 'Trace', value: 'trace' },
+  { label: 'Node Graph', value: 'node_graph' },
+  { label: 'Heatmap', value: 'heatmap' },
 ];
 
 const InlineTableSelect = (props: InlineTableSelectProps) => {
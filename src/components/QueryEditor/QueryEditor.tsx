@@ -1,18 +1,28 @@
 import { QueryEditorProps } from '@grafana/data';
-import { Alert } from '@grafana/ui';
+import { Alert, InlineField, RadioButtonGroup } from '@grafana/ui';
 import { get } from 'lodash';
 import React, { useMemo, useState } from 'react';
 import { useAsync, useEffectOnce } from 'react-use';
 import { LogshipDataSourceOptions as LogshipDataSourceOptions, KustoQuery } from 'types';
 import { LogshipDataSource } from '../../datasource';
 import { QueryHeader } from './QueryHeader';
+import { FunctionQueryEditor } from './FunctionQueryEditor';
+import { FormatOptions } from './QueryOptions';
 import { RawQueryEditor } from './RawQueryEditor';
 import { useLocation } from 'react-router-dom';
 
-type Props = QueryEditorProps<LogshipDataSource, KustoQuery, LogshipDataSourceOptions>;
+type Props = QueryEditorProps<LogshipDataSource, KustoQuery, LogshipDataSourceOptions> & {
+  // functionQueries shows the choice between KQL and stored function queries.
+  functionQueries?: boolean;
+};
+
+const QUERY_TYPES = [
+  { label: 'KQL', value: 'raw' },
+  { label: 'Stored function', value: 'function' },
+];
 
 export const QueryEditor: React.FC<Props> = (props) => {
-  const { onChange, onRunQuery, query, datasource } = props;
+  const { onChange, onRunQuery, query, datasource, functionQueries = true } = props;
   const schema = useAsync(() => datasource.getSchema(true), [datasource.id]);
   const templateVariables = useTemplateVariables(datasource);
   const [dirty, setDirty] = useState(false);
@@ -34,12 +44,26 @@ export const QueryEditor: React.FC<Props> = (props) => {
           setDirty={setDirty}
           onRunQuery={onRunQuery}
         />
-        <RawQueryEditor
+        {functionQueries && (
+          <InlineField label="Query type" labelWidth={18}>
+            <RadioButtonGroup
+              options={QUERY_TYPES}
+              value={query.queryType === 'function' ? 'function' : 'raw'}
+              onChange={(queryType) => onChange({ ...query, queryType })}
+            />
+          </InlineField>
+        )}
+        {query.queryType === 'function' ? (
+          <FunctionQueryEditor datasource={datasource} query={query} onChange={onChange} />
+        ) : (
+          <RawQueryEditor
             {...props}
             schema={schema}
             templateVariableOptions={templateVariables}
             setDirty={() => !dirty && setDirty(true)}
           />
+        )}
+        <FormatOptions query={query} onChange={onChange} />
     </>
   );
 };
@@ -0,0 +1,248 @@
+import { SelectableValue } from '@grafana/data';
+import { InlineField, InlineFieldRow, Input, Select } from '@grafana/ui';
+import React from 'react';
+import { KustoQuery, QueryResultFormat } from 'types';
+
+export interface QueryOption {
+  key: keyof KustoQuery;
+  label: string;
+  tooltip: string;
+  // list options are comma separated column names
+  type?: 'list' | 'number';
+  placeholder?: string;
+  // choices of options that are selected rather than typed
+  choices?: Array<SelectableValue<string>>;
+}
+
+export const ANNOTATION_OPTIONS: QueryOption[] = [
+  { key: 'timeColumn', label: 'Time', tooltip: 'Column with the time of the annotation. Detected from timestamp, time, startTime or start by default.' },
+  {
+    key: 'timeEndColumn',
+    label: 'Time end',
+    tooltip: 'Column with the end time of region annotations. Detected from timeEnd, endTime, end_time or end by default.',
+  },
+  { key: 'titleColumn', label: 'Title', tooltip: 'Column with the title of the annotation. Detected from title, name or summary by default.' },
+  {
+    key: 'textColumn',
+    label: 'Text',
+    tooltip: 'Column with the text of the annotation. Detected from text, message, description, body or details by default.',
+  },
+  {
+    key: 'tagsColumns',
+    label: 'Tags',
+    tooltip: 'Comma separated columns that become the tags of the annotation. Detected from tags or labels by default.',
+    type: 'list',
+  },
+];
+
+export const TRACE_OPTIONS: QueryOption[] = [
+  { key: 'traceIdColumn', label: 'Trace ID', tooltip: 'Column with the trace ID. Detected from traceId or trace_id by default.' },
+  { key: 'spanIdColumn', label: 'Span ID', tooltip: 'Column with the span ID. Detected from spanId, span_id or id by default.' },
+  {
+    key: 'parentSpanIdColumn',
+    label: 'Parent span ID',
+    tooltip: 'Column with the ID of the parent span. Detected from parentSpanId, parent_span_id, parentId or parent_id by default.',
+  },
+  {
+    key: 'operationNameColumn',
+    label: 'Operation name',
+    tooltip: 'Column with the name of the span. Detected from operationName, spanName, name or operation by default.',
+  },
+  {
+    key: 'serviceNameColumn',
+    label: 'Service name',
+    tooltip: 'Column with the service of the span. Detected from serviceName, service_name or service by default.',
+  },
+  {
+    key: 'startTimeColumn',
+    label: 'Start time',
+    tooltip: 'Column with the start time of the span. Detected from startTime, timestamp, time or start by default.',
+  },
+  {
+    key: 'durationColumn',
+    label: 'Duration',
+    tooltip: 'Column with the duration of the span, a timespan or milliseconds. Detected from duration, durationMs or elapsed by default.',
+  },
+  {
+    key: 'spanTagsColumns',
+    label: 'Tags',
+    tooltip: 'Comma separated columns that become the span tags. Detected from tags, attributes or properties, otherwise every remaining column is a tag.',
+    type: 'list',
+  },
+];
+
+export const NODE_GRAPH_OPTIONS: QueryOption[] = [
+  {
+    key: 'sourceColumn',
+    label: 'Source',
+    tooltip: 'Column with the node an edge starts at. Detected from source, caller, from, client or parent by default.',
+  },
+  {
+    key: 'targetColumn',
+    label: 'Target',
+    tooltip: 'Column with the node an edge ends at. Detected from target, callee, to, server or child by default.',
+  },
+  {
+    key: 'mainStatColumn',
+    label: 'Main stat',
+    tooltip: 'Numeric column shown as the main stat of nodes and edges. Detected from mainStat, count, calls or requests by default.',
+  },
+  {
+    key: 'secondaryStatColumn',
+    label: 'Secondary stat',
+    tooltip: 'Numeric column shown as the secondary stat of nodes and edges. Detected from secondaryStat, latency or duration by default.',
+  },
+];
+
+export const HEATMAP_OPTIONS: QueryOption[] = [
+  {
+    key: 'bucketColumn',
+    label: 'Bucket',
+    tooltip: 'Numeric column with the lower bound of the buckets. Detected from bucket, value, le or yMin by default.',
+  },
+  {
+    key: 'countColumn',
+    label: 'Count',
+    tooltip: 'Numeric column with the count of each bucket. Detected from count or count_ by default.',
+  },
+  {
+    key: 'bucketSize',
+    label: 'Bucket size',
+    tooltip: 'Size passed to bin() for the buckets. By default it is the smallest distance between two buckets of the result.',
+    type: 'number',
+  },
+];
+
+// TIME_SHIFT_OPTIONS apply to every format but trace, which rejects a time shift.
+export const TIME_SHIFT_OPTIONS: QueryOption[] = [
+  {
+    key: 'timeShift',
+    label: 'Time shift',
+    tooltip: 'KQL timespan the time range of the query is moved by, e.g. -7d compares with the week before. Time fields are moved back into the time range of the panel.',
+    placeholder: 'none',
+  },
+];
+
+// FRAME_OPTIONS apply to the results of every format.
+export const FRAME_OPTIONS: QueryOption[] = [
+  {
+    key: 'geoMode',
+    label: 'Geo',
+    tooltip: 'Adds latitude and longitude, or geohash, fields for Dynamic columns of GeoJSON values, e.g. for the geomap panel.',
+    choices: [
+      { label: 'None', value: 'none' },
+      { label: 'Coordinates', value: 'coords' },
+      { label: 'Geohash', value: 'geohash' },
+    ],
+  },
+  {
+    key: 'geohashPrecision',
+    label: 'Geohash precision',
+    tooltip: 'Number of characters of the geohashes, from 1 to 12.',
+    type: 'number',
+    placeholder: '12',
+  },
+  {
+    key: 'flattenDepth',
+    label: 'Flatten depth',
+    tooltip: 'Replaces Dynamic columns of objects by a field per key, flattening nested objects up to this many levels. 0 keeps the columns as they are.',
+    type: 'number',
+    placeholder: '0',
+  },
+];
+
+const FORMAT_OPTIONS: Partial<Record<QueryResultFormat, QueryOption[]>> = {
+  trace: TRACE_OPTIONS,
+  node_graph: NODE_GRAPH_OPTIONS,
+  heatmap: HEATMAP_OPTIONS,
+};
+
+const LABEL_WIDTH = 18;
+
+interface QueryOptionFieldsProps {
+  query: KustoQuery;
+  options: QueryOption[];
+  onChange: (query: KustoQuery) => void;
+}
+
+// QueryOptionFields edits options of the query. Options left empty are detected
+// from the columns of the result by the backend.
+export const QueryOptionFields = ({ query, options, onChange }: QueryOptionFieldsProps) => (
+  <InlineFieldRow>
+    {options.map((option) => (
+      <InlineField key={option.key} label={option.label} labelWidth={LABEL_WIDTH} tooltip={option.tooltip}>
+        {option.choices ? (
+          <Select
+            inputId={`logship-${option.key}`}
+            width={20}
+            options={option.choices}
+            value={(query[option.key] as string | undefined) ?? option.choices[0].value}
+            onChange={({ value }) => onChange({ ...query, [option.key]: value })}
+          />
+        ) : (
+          <Input
+            id={`logship-${option.key}`}
+            width={20}
+            type={option.type === 'number' ? 'number' : 'text'}
+            placeholder={option.placeholder ?? 'auto'}
+            value={formatOption(query[option.key], option.type)}
+            onChange={(ev: React.ChangeEvent<HTMLInputElement>) =>
+              onChange({ ...query, [option.key]: parseOption(ev.target.value, option.type) })
+            }
+            onBlur={() => onChange({ ...query, [option.key]: cleanOption(query[option.key], option.type) })}
+          />
+        )}
+      </InlineField>
+    ))}
+  </InlineFieldRow>
+);
+
+// FormatOptions edits the options of the results and of the result format of the query.
+export const FormatOptions = ({ query, onChange }: Omit<QueryOptionFieldsProps, 'options'>) => {
+  const options = FORMAT_OPTIONS[query.resultFormat];
+  return (
+    <>
+      {query.resultFormat !== 'trace' && (
+        <QueryOptionFields query={query} options={TIME_SHIFT_OPTIONS} onChange={onChange} />
+      )}
+      <QueryOptionFields query={query} options={FRAME_OPTIONS} onChange={onChange} />
+      {options && <QueryOptionFields query={query} options={options} onChange={onChange} />}
+    </>
+  );
+};
+
+const formatOption = (value: unknown, type: QueryOption['type']): string => {
+  if (value === undefined || value === null) {
+    return '';
+  }
+  if (type === 'list') {
+    return (value as string[]).join(', ');
+  }
+  return String(value);
+};
+
+// parseOption keeps what is typed, e.g. the empty column after a trailing comma,
+// so that the value can be edited. cleanOption drops it once the field is left.
+const parseOption = (value: string, type: QueryOption['type']): string | string[] | number | undefined => {
+  if (value.trim() === '') {
+    return undefined;
+  }
+  switch (type) {
+    case 'list':
+      return value.split(',').map((column) => column.trim());
+    case 'number':
+      return Number(value);
+  }
+  return value;
+};
+
+const cleanOption = (value: unknown, type: QueryOption['type']): unknown => {
+  if (type === 'list' && Array.isArray(value)) {
+    const columns = value.filter((column) => column !== '');
+    return columns.length ? columns : undefined;
+  }
+  if (typeof value === 'string') {
+    return value.trim() || undefined;
+  }
+  return value;
+};
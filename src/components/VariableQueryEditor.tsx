@@ -0,0 +1,85 @@
+import { SelectableValue } from '@grafana/data';
+import { InlineField, InlineFieldRow, Input, Select, TextArea } from '@grafana/ui';
+import React from 'react';
+import { LogshipVariableQuery, VariableSort } from 'types';
+
+interface VariableQueryEditorProps {
+  query: LogshipVariableQuery | string;
+  onChange: (query: LogshipVariableQuery, definition: string) => void;
+}
+
+const SORTS: Array<SelectableValue<VariableSort>> = [
+  { label: 'None', value: 'none' },
+  { label: 'Alphabetical (asc)', value: 'asc' },
+  { label: 'Alphabetical (desc)', value: 'desc' },
+  { label: 'Numerical (asc)', value: 'numericAsc' },
+  { label: 'Numerical (desc)', value: 'numericDesc' },
+];
+
+const LABEL_WIDTH = 20;
+
+// VariableQueryEditor edits the query of template variables. The text of the values
+// comes from the text or __text column, their value from the value or __value
+// column, and both default to the first column.
+export const VariableQueryEditor = ({ query, onChange }: VariableQueryEditorProps) => {
+  const variableQuery: LogshipVariableQuery = typeof query === 'string' ? { query } : query;
+
+  const update = (change: Partial<LogshipVariableQuery>) => {
+    const updated = { ...variableQuery, ...change };
+    onChange(updated, updated.query);
+  };
+
+  return (
+    <>
+      <InlineFieldRow>
+        <InlineField label="Query" labelWidth={LABEL_WIDTH} grow>
+          <TextArea
+            id="logship-variable-query"
+            rows={3}
+            value={variableQuery.query}
+            onChange={(ev: React.ChangeEvent<HTMLTextAreaElement>) => update({ query: ev.target.value })}
+          />
+        </InlineField>
+      </InlineFieldRow>
+      <InlineFieldRow>
+        <InlineField label="Database" labelWidth={LABEL_WIDTH} tooltip="Database of the query, the default database by default.">
+          <Input
+            id="logship-variable-database"
+            width={30}
+            placeholder="default"
+            value={variableQuery.database ?? ''}
+            onChange={(ev: React.ChangeEvent<HTMLInputElement>) => update({ database: ev.target.value || undefined })}
+          />
+        </InlineField>
+      </InlineFieldRow>
+      <InlineFieldRow>
+        <InlineField
+          label="Server-side regex"
+          labelWidth={LABEL_WIDTH}
+          tooltip="Filters the values on the server before they are returned, e.g. to extract them with a capture group. Use the regex of the variable otherwise, it is applied to the values returned."
+        >
+          <Input
+            id="logship-variable-regex"
+            width={30}
+            placeholder="/.*-(?<text>.*)/"
+            value={variableQuery.regex ?? ''}
+            onChange={(ev: React.ChangeEvent<HTMLInputElement>) => update({ regex: ev.target.value || undefined })}
+          />
+        </InlineField>
+        <InlineField
+          label="Server-side sort"
+          labelWidth={LABEL_WIDTH}
+          tooltip="Sorts the values on the server. By default they keep the order of the query, and are sorted as set in the variable."
+        >
+          <Select
+            inputId="logship-variable-sort"
+            width={30}
+            options={SORTS}
+            value={variableQuery.sort ?? 'none'}
+            onChange={(change: SelectableValue<VariableSort>) => update({ sort: change.value })}
+          />
+        </InlineField>
+      </InlineFieldRow>
+    </>
+  );
+};
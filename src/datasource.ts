@@ -7,7 +7,6 @@ import {
   ScopedVars,
 } from '@grafana/data';
 import { DataSourceWithBackend, getTemplateSrv, TemplateSrv } from '@grafana/runtime';
-import { firstFieldToMetricFindValue } from 'common/responseHelpers';
 import { QueryEditorPropertyType } from './schema/types';
 import { map } from 'lodash';
 import { cache } from 'schema/cache';
@@ -17,28 +16,38 @@ import { ResponseParser } from './response_parser';
 import {
   LogshipColumnSchema,
   LogshipDataSourceOptions,
+  LogshipFunctionSchema,
+  LogshipVariableQuery,
   LogshipSchemaDefinition,
   defaultQuery,
   KustoQuery,
   LogshipDatabaseSchema,
+  LogshipSchema,
 } from './types';
 import { LogshipSchemaMapper } from 'schema/LogshipSchemaMapper';
+import { AnnotationQueryEditor } from 'components/QueryEditor/AnnotationQueryEditor';
 
 export class LogshipDataSource extends DataSourceWithBackend<KustoQuery, LogshipDataSourceOptions> {
   private templateSrv: TemplateSrv;
   private schemaMapper: LogshipSchemaMapper;
+  private defaultDatabase: string;
 
   constructor(instanceSettings: DataSourceInstanceSettings<LogshipDataSourceOptions>) {
     super(instanceSettings);
 
     const useSchemaMapping = instanceSettings.jsonData.useSchemaMapping ?? false;
     const schemaMapping = instanceSettings.jsonData.schemaMappings ?? [];
+    this.defaultDatabase = instanceSettings.jsonData.defaultDatabase ?? '';
 
     //this.backendSrv = getBackendSrv();
     this.templateSrv = getTemplateSrv();
     //this.url = instanceSettings.url;
     this.schemaMapper = new LogshipSchemaMapper(useSchemaMapping, schemaMapping);
     this.getSchemaMapper = this.getSchemaMapper.bind(this);
+    this.annotations = {
+      prepareQuery: (anno) => (anno.target ? { ...anno.target, queryType: 'annotations' } : undefined),
+      QueryEditor: AnnotationQueryEditor,
+    };
   }
 
   getDefaultQuery(app: CoreApp): Partial<KustoQuery> {
@@ -52,6 +61,9 @@ export class LogshipDataSource extends DataSourceWithBackend<KustoQuery, Logship
    * Return true if it should execute
    */
   filterQuery(target: KustoQuery): boolean {
+    if (target.queryType === 'function') {
+      return !target.hide && !!target.function;
+    }
     if (target.hide || !target.query || target.query.trim() === '') {
       return false;
     }
@@ -66,46 +78,66 @@ export class LogshipDataSource extends DataSourceWithBackend<KustoQuery, Logship
       scopedVars
     );
 
+    // parameter values of stored function calls are formatted as literals by the backend
+    const parameters = target.parameters?.map((parameter) =>
+      typeof parameter.value === 'string'
+        ? { ...parameter, value: this.templateSrv.replace(parameter.value, scopedVars) }
+        : parameter
+    );
+
     return {
       ...target,
       query,
+      parameters,
     };
   }
 
-  async metricFindQuery(query: string, optionalOptions: any): Promise<MetricFindValue[]> {
-    const q = this.buildQuery(query, optionalOptions, 'default')
-    return this.query({
-      targets: [
-        {
-          ...q,
-          querySource: 'variable',
-        },
-      ],
-    } as DataQueryRequest<KustoQuery>).toPromise()
-      .then((response) => {
-        if (response?.data && response.data.length) {
-          return firstFieldToMetricFindValue(response.data[0]);
-        }
-        return [];
-      })
-      .catch((err) => {
-        console.log('There was an error', err);
-        throw err;
-      });
+  async metricFindQuery(query: string | LogshipVariableQuery, optionalOptions: any): Promise<MetricFindValue[]> {
+    // Grafana applies the regex and sort of the variable to the values returned,
+    // the variable query only filters and sorts them on the server when it says so
+    const variableQuery = typeof query === 'string' ? { query } : query;
+    const q = this.buildQuery(variableQuery.query, optionalOptions, variableQuery.database || this.defaultDatabase);
+    const range = optionalOptions?.range;
+    return this.postResource('variables', {
+      query: q.query,
+      database: q.database,
+      regex: variableQuery.regex,
+      sort: variableQuery.sort,
+      from: range?.from?.valueOf(),
+      to: range?.to?.valueOf(),
+    }).catch((err) => {
+      console.log('There was an error', err);
+      throw err;
+    });
   }
 
   async getResource<T = unknown>(path: string): Promise<any> {
     return super.getResource(path);
   }
 
-  async getSchema(refreshCache = false): Promise<LogshipDatabaseSchema> {
-    return await cache<LogshipDatabaseSchema>(
+  // getStoredFunctions lists the functions stored in the database that queries of
+  // the function type can call. Saved query functions are expanded by $__fn instead.
+  async getStoredFunctions(database?: string): Promise<LogshipFunctionSchema[]> {
+    const functions: LogshipFunctionSchema[] = await super.getResource('functions', {
+      database: database || this.defaultDatabase,
+    });
+    return functions.filter((fn) => fn.FunctionKind !== 'SavedQuery');
+  }
+
+  async getSchemas(refreshCache = false): Promise<LogshipSchema> {
+    return await cache<LogshipSchema>(
       `${this.id}.schema.overview`,
       () => this.getResource('schema').then(new ResponseParser().parseSchemaResult),
       refreshCache
     );
   }
 
+  async getSchema(refreshCache = false, database?: string): Promise<LogshipDatabaseSchema> {
+    const schema = await this.getSchemas(refreshCache);
+    const name = database || this.defaultDatabase;
+    return schema.databases.find((db) => db.name === name) ?? schema.databases[0] ?? { name, tables: [] };
+  }
+
   async getFunctionSchema(database: string, targetFunction: string): Promise<LogshipColumnSchema[]> {
     const queryParts: string[] = [];
     const take = 'take 50000';
@@ -151,6 +183,7 @@ export class LogshipDataSource extends DataSourceWithBackend<KustoQuery, Logship
       refId: `logship-${interpolatedQuery}`,
       resultFormat: 'table',
       query: interpolatedQuery,
+      database,
     };
   }
 
@@ -4,10 +4,12 @@ import { QueryEditor } from './components/QueryEditor';
 import { KustoQuery, LogshipDataSourceOptions, LogshipDataSourceSecureOptions } from './types';
 import ConfigEditor from 'components/ConfigEditor';
 import EditorHelp from 'components/QueryEditor/EditorHelp';
+import { VariableQueryEditor } from 'components/VariableQueryEditor';
 
 export const plugin = new DataSourcePlugin<LogshipDataSource, KustoQuery, LogshipDataSourceOptions, LogshipDataSourceSecureOptions>(
   LogshipDataSource
 )
   .setConfigEditor(ConfigEditor)
   .setQueryEditorHelp(EditorHelp)
-  .setQueryEditor(QueryEditor);
+  .setQueryEditor(QueryEditor)
+  .setVariableQueryEditor(VariableQueryEditor);
@@ -1,4 +1,4 @@
-import { LogshipDatabaseSchema } from 'types';
+import { LogshipSchema } from 'types';
 
 export interface DataTarget {
   target: string;
@@ -84,7 +84,7 @@ export class ResponseParser {
     return databases;
   }
 
-  parseSchemaResult(results: any): LogshipDatabaseSchema {
-    return results as LogshipDatabaseSchema;
+  parseSchemaResult(results: any): LogshipSchema {
+    return results as LogshipSchema;
   }
 }
@@ -6,13 +6,59 @@ import { DataQuery } from '@grafana/schema';
 const packageJson = require('../package.json');
 
 export type QuerySource = 'raw' | 'schema' | 'autocomplete' | 'variable';
-export type QueryResultFormat = 'time_series' | 'table';
+export type QueryResultFormat = 'time_series' | 'table' | 'trace' | 'node_graph' | 'heatmap';
 
 export interface KustoQuery extends DataQuery {
   query: string;
   resultFormat: QueryResultFormat;
   querySource: QuerySource;
   pluginVersion: string;
+  timeShift?: string;
+  database?: string;
+  function?: string;
+  parameters?: FunctionParameter[];
+  timeColumn?: string;
+  timeEndColumn?: string;
+  titleColumn?: string;
+  textColumn?: string;
+  tagsColumns?: string[];
+  traceIdColumn?: string;
+  spanIdColumn?: string;
+  parentSpanIdColumn?: string;
+  operationNameColumn?: string;
+  serviceNameColumn?: string;
+  startTimeColumn?: string;
+  durationColumn?: string;
+  spanTagsColumns?: string[];
+  sourceColumn?: string;
+  targetColumn?: string;
+  mainStatColumn?: string;
+  secondaryStatColumn?: string;
+  bucketColumn?: string;
+  countColumn?: string;
+  bucketSize?: number;
+  geoMode?: GeoMode;
+  geohashPrecision?: number;
+  flattenDepth?: number;
+}
+
+// LogshipVariableQuery is the query of a template variable. The regex and sort
+// filter and sort the values on the server, on top of those of the variable.
+export interface LogshipVariableQuery {
+  query: string;
+  database?: string;
+  regex?: string;
+  sort?: VariableSort;
+}
+
+export type VariableSort = 'none' | 'asc' | 'desc' | 'numericAsc' | 'numericDesc';
+
+export type GeoMode = 'none' | 'coords' | 'geohash';
+
+export interface FunctionParameter {
+  name: string;
+  type: string;
+  value: unknown;
 }
 
 export const defaultQuery: Pick<KustoQuery, 'query' | 'querySource' | 'pluginVersion'> = {
@@ -49,10 +95,15 @@ export interface LogshipDataSourceOptions extends DataSourceJsonData {
   minimalCache: number;
   queryTimeout: string;
   cacheMaxAge: string;
+  schemaCacheTtl?: string;
   dynamicCaching: boolean;
   useSchemaMapping: boolean;
   schemaMappings?: Array<Partial<SchemaMapping>>;
+  enforceSchemaMapping?: boolean;
+  int128AsString?: boolean;
+  typeMappings?: Record<string, string>;
   enableUserTracking: boolean;
+  showStackTrace?: boolean;
   clusterUrl: string;
   authType: string;
   username: string;
@@ -60,6 +111,14 @@ export interface LogshipDataSourceOptions extends DataSourceJsonData {
   tokenEndpoint: string | undefined;
   oauthPassThru?: boolean;
   scope?: string;
+  queryFunctions?: QueryFunction[];
+}
+
+export interface QueryFunction {
+  name: string;
+  parameters: string[];
+  body: string;
+  description?: string;
 }
 
 export interface LogshipDataSourceSecureOptions {
@@ -67,6 +126,10 @@ export interface LogshipDataSourceSecureOptions {
   clientSecret: string | undefined;
 }
 
+export interface LogshipSchema {
+  databases: LogshipDatabaseSchema[];
+}
+
 export interface LogshipDatabaseSchema {
   name: string;
   tables: LogshipTableSchema[];